@@ -2,7 +2,6 @@ package content
 
 import (
 	"github.com/cryptopunkscc/astrald/auth/id"
-	"github.com/cryptopunkscc/astrald/lib/astral"
 	rpc "github.com/cryptopunkscc/go-apphost-jrpc"
 	"github.com/cryptopunkscc/go-apphost-jrpc/android"
 	"io"
@@ -14,10 +13,7 @@ type Client struct {
 }
 
 func (c *Client) Connect() (err error) {
-	conn, err := astral.Query(c.Identity, android.ContentPort)
-	if err == nil {
-		c.Conn = rpc.NewFlow(conn)
-	}
+	c.Conn, err = rpc.QueryFlow(c.Identity, android.ContentPort)
 	return
 }
 
@@ -2,11 +2,19 @@ package notify
 
 import (
 	"context"
+	"github.com/cryptopunkscc/go-apphost-jrpc"
 	"github.com/cryptopunkscc/go-apphost-jrpc/android"
+	"github.com/cryptopunkscc/go-apphost-jrpc/jrpctest"
+	"os"
 	"testing"
 	"time"
 )
 
+func TestMain(m *testing.M) {
+	jrpc.DefaultApphost = jrpctest.New()
+	os.Exit(m.Run())
+}
+
 func TestClient_All(t *testing.T) {
 	cancelServer := TestServer(true)
 	defer cancelServer()
@@ -0,0 +1,17 @@
+package jrpc
+
+import (
+	"github.com/cryptopunkscc/astrald/auth/id"
+	"io"
+)
+
+type Dialer interface {
+	Query(identity id.Identity, query string) (io.ReadWriteCloser, error)
+}
+
+type Apphost interface {
+	Listener
+	Dialer
+}
+
+var DefaultApphost Apphost = NewAstralApphost()
@@ -0,0 +1,63 @@
+package jrpc
+
+import (
+	"context"
+	"github.com/cryptopunkscc/astrald/auth/id"
+	"github.com/cryptopunkscc/astrald/lib/astral"
+	"io"
+)
+
+type astralApphost struct{}
+
+func NewAstralApphost() Apphost {
+	return astralApphost{}
+}
+
+func (astralApphost) Query(identity id.Identity, query string) (io.ReadWriteCloser, error) {
+	conn, err := astral.Query(identity, query)
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (astralApphost) Listen(ctx context.Context, route string) (<-chan IncomingQuery, error) {
+	listener, err := astral.Register(route)
+	if err != nil {
+		return nil, err
+	}
+	out := make(chan IncomingQuery)
+	go func() {
+		defer close(out)
+		defer listener.Close()
+		done := ctx.Done()
+		queries := listener.QueryCh()
+		for {
+			select {
+			case <-done:
+				return
+			case q, ok := <-queries:
+				if !ok {
+					return
+				}
+				select {
+				case out <- astralQuery{q}:
+				case <-done:
+					_ = q.Reject()
+					return
+				}
+			}
+		}
+	}()
+	return out, nil
+}
+
+type astralQuery struct{ *astral.QueryData }
+
+func (q astralQuery) Accept() (io.ReadWriteCloser, error) {
+	conn, err := q.QueryData.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
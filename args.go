@@ -21,6 +21,14 @@ func (a *argsDecoders) Append(decoders []ArgsDecoder) *argsDecoders {
 	return a
 }
 
+// Prepend returns a copy of decoders preferring the given ones.
+func (a argsDecoders) Prepend(decoders []ArgsDecoder) argsDecoders {
+	if len(decoders) == 0 {
+		return a
+	}
+	return argsDecoders{append(append([]ArgsDecoder{}, decoders...), a.decoders...)}
+}
+
 func (a *argsDecoders) TestScan(scan io.ByteScanner) (b bool) {
 	return a.find(scan) != nil
 }
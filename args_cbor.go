@@ -0,0 +1,170 @@
+package jrpc
+
+import (
+	"errors"
+	"github.com/fxamacker/cbor/v2"
+	"io"
+)
+
+type cborArgsDecoder struct{}
+
+func NewCborArgsDecoder() ArgsDecoder {
+	return &cborArgsDecoder{}
+}
+
+// isCborArgs checks if the byte is a head of CBOR array or map.
+func isCborArgs(b byte) bool {
+	return b >= 0x80 && b <= 0xbf
+}
+
+func (d cborArgsDecoder) Test(b []byte) bool {
+	return len(b) > 0 && isCborArgs(b[0])
+}
+
+func (d cborArgsDecoder) TestScan(scan io.ByteScanner) bool {
+	b, err := scan.ReadByte()
+	_ = scan.UnreadByte()
+	return err == nil && isCborArgs(b)
+}
+
+func (d cborArgsDecoder) Unmarshal(bytes []byte, args []any) (err error) {
+	if len(args) == 1 {
+		// unmarshal map payload as first arg
+		if bytes[0] >= 0xa0 {
+			return cbor.Unmarshal(bytes, args[0])
+		}
+	}
+
+	// unmarshal array items one by one
+	var items []cbor.RawMessage
+	if err = cbor.Unmarshal(bytes, &items); err != nil {
+		return
+	}
+	for i := 0; i < len(args) && i < len(items); i++ {
+		if err = cbor.Unmarshal(items[i], args[i]); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (d cborArgsDecoder) Decode(conn ByteScannerReader, args []any) (err error) {
+	// read exactly one item, the connection may already carry the next command
+	item := cborItemReader{reader: conn}
+	if err = item.read(); err != nil {
+		return
+	}
+
+	// drop line terminator
+	if b, err := conn.ReadByte(); err == nil && b != '\n' {
+		_ = conn.UnreadByte()
+	}
+	return d.Unmarshal(item.bytes, args)
+}
+
+var ErrCborTooLarge = errors.New("cbor item too large")
+var ErrCborMalformed = errors.New("malformed cbor item")
+
+const cborMaxItemLen = 16 << 20
+const cborMaxDepth = 256
+
+// cborItemReader collects bytes of a single CBOR data item without reading ahead.
+type cborItemReader struct {
+	reader io.ByteReader
+	bytes  []byte
+	depth  int
+}
+
+func (c *cborItemReader) read() (err error) {
+	b, err := c.byte()
+	if err != nil {
+		return
+	}
+	return c.item(b)
+}
+
+func (c *cborItemReader) byte() (b byte, err error) {
+	if b, err = c.reader.ReadByte(); err != nil {
+		return
+	}
+	if c.bytes = append(c.bytes, b); len(c.bytes) > cborMaxItemLen {
+		err = ErrCborTooLarge
+	}
+	return
+}
+
+func (c *cborItemReader) item(head byte) (err error) {
+	if c.depth++; c.depth > cborMaxDepth {
+		return ErrCborMalformed
+	}
+	defer func() { c.depth-- }()
+
+	major, info := head>>5, head&0x1f
+	if info == 31 {
+		return c.indefinite(major)
+	}
+	n, err := c.argument(info)
+	if err != nil {
+		return
+	}
+	switch major {
+	case 2, 3:
+		for ; n > 0; n-- {
+			if _, err = c.byte(); err != nil {
+				return
+			}
+		}
+	case 4, 5, 6:
+		if major == 5 {
+			n *= 2
+		} else if major == 6 {
+			n = 1
+		}
+		for ; n > 0; n-- {
+			if err = c.read(); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+func (c *cborItemReader) indefinite(major byte) (err error) {
+	if major < 2 || major > 5 {
+		return ErrCborMalformed
+	}
+	var b byte
+	for {
+		if b, err = c.byte(); err != nil || b == 0xff {
+			return
+		}
+		if err = c.item(b); err != nil {
+			return
+		}
+		if major == 5 {
+			if err = c.read(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (c *cborItemReader) argument(info byte) (n uint64, err error) {
+	var size int
+	switch {
+	case info < 24:
+		return uint64(info), nil
+	case info <= 27:
+		size = 1 << (info - 24)
+	default:
+		return 0, ErrCborMalformed
+	}
+	var b byte
+	for ; size > 0; size-- {
+		if b, err = c.byte(); err != nil {
+			return
+		}
+		n = n<<8 | uint64(b)
+	}
+	return
+}
@@ -0,0 +1,68 @@
+package jrpc
+
+import (
+	"github.com/vmihailenco/msgpack/v5"
+	"io"
+)
+
+// msgpackArgsDecoder decodes MessagePack array or map arguments. The heads of
+// small msgpack arrays and maps are valid CBOR heads as well, so the decoder
+// is not registered by default and has to be selected with Router.Decoders.
+type msgpackArgsDecoder struct{}
+
+func NewMsgpackArgsDecoder() ArgsDecoder {
+	return &msgpackArgsDecoder{}
+}
+
+func isMsgpackArgs(b byte) bool {
+	return b >= 0x80 && b <= 0x9f || b >= 0xdc && b <= 0xdf
+}
+
+func isMsgpackMap(b byte) bool {
+	return b >= 0x80 && b <= 0x8f || b == 0xde || b == 0xdf
+}
+
+func (d msgpackArgsDecoder) Test(b []byte) bool {
+	return len(b) > 0 && isMsgpackArgs(b[0])
+}
+
+func (d msgpackArgsDecoder) TestScan(scan io.ByteScanner) bool {
+	b, err := scan.ReadByte()
+	_ = scan.UnreadByte()
+	return err == nil && isMsgpackArgs(b)
+}
+
+func (d msgpackArgsDecoder) Unmarshal(bytes []byte, args []any) (err error) {
+	if len(args) == 1 {
+		// unmarshal map payload as first arg
+		if isMsgpackMap(bytes[0]) {
+			return msgpackUnmarshal(bytes, args[0])
+		}
+	}
+
+	// unmarshal array items one by one
+	var items []msgpack.RawMessage
+	if err = msgpackUnmarshal(bytes, &items); err != nil {
+		return
+	}
+	for i := 0; i < len(args) && i < len(items); i++ {
+		if err = msgpackUnmarshal(items[i], args[i]); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (d msgpackArgsDecoder) Decode(conn ByteScannerReader, args []any) (err error) {
+	// the decoder reads the connection as io.ByteScanner, so it does not read ahead
+	item, err := msgpack.NewDecoder(conn).DecodeRaw()
+	if err != nil {
+		return
+	}
+
+	// drop line terminator
+	if b, err := conn.ReadByte(); err == nil && b != '\n' {
+		_ = conn.UnreadByte()
+	}
+	return d.Unmarshal(item, args)
+}
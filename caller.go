@@ -1,20 +1,43 @@
 package jrpc
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
 	"reflect"
+	"runtime/debug"
+	"sync"
+	"time"
 )
 
 type Caller struct {
-	name    string
-	env     []any
-	decoder argsDecoders
-	f       reflect.Value
-	args    []reflect.Value
+	name         string
+	env          []any
+	decoder      argsDecoders
+	interceptors []Interceptor
+	logger       *log.Logger
+	debug        bool
+	input        *Serializer
+	hangup       func()
+	timeout      time.Duration
+	f            reflect.Value
+	args         []reflect.Value
+}
+
+var ErrTaggedStreamArgs = errors.New("stream arguments are not supported in tagged calls")
+var ErrStreamArgsLimit = errors.New("only one stream argument is supported")
+var ErrPanic = RegisterError("panic", errors.New("panic"))
+var ErrInvalidArgs = RegisterError("invalid_args", NewError(CodeMalformedRequest, "invalid arguments"))
+
+// invalidArgs converts the error of decoding arguments into ErrInvalidArgs with its message.
+func invalidArgs(err error) error {
+	return &Error{Code: CodeMalformedRequest, Message: ErrInvalidArgs.Error() + ": " + err.Error(), err: ErrInvalidArgs}
 }
 
 func NewCaller(name string) (c *Caller) {
 	c = &Caller{name: name}
-	c.Decoder(NewJsonArgsDecoder(), NewClirArgsDecoder())
+	c.Decoder(NewJsonArgsDecoder(), NewClirArgsDecoder(), NewCborArgsDecoder())
 	return
 }
 
@@ -40,6 +63,19 @@ func (exec *Caller) Decoders(decoders []ArgsDecoder) *Caller {
 	return exec
 }
 
+// Use adds interceptors running around the calls of the function.
+func (exec *Caller) Use(interceptors ...Interceptor) *Caller {
+	exec.interceptors = append(exec.interceptors, interceptors...)
+	return exec
+}
+
+// Timeout limits the time of the call handled by the router. The context of
+// the handler is cancelled and the deadline error is sent when it is exceeded.
+func (exec *Caller) Timeout(timeout time.Duration) *Caller {
+	exec.timeout = timeout
+	return exec
+}
+
 func (exec *Caller) Call(args ByteScannerReader) (out []any, err error) {
 	values, err := exec.call(args)
 	if err != nil {
@@ -49,11 +85,132 @@ func (exec *Caller) Call(args ByteScannerReader) (out []any, err error) {
 	return
 }
 
+// Bind decodes the arguments and returns the call to run later, so the
+// arguments can be read from the connection before the call runs concurrently.
+// The bound call is detached from the args, nested functions get no arguments.
+func (exec *Caller) Bind(args ByteScannerReader) (call func() ([]any, error), err error) {
+	values, injected, stream, err := exec.decodeIn(args)
+	if err != nil {
+		return
+	}
+	if stream.IsValid() {
+		return nil, ErrTaggedStreamArgs
+	}
+	call = func() (out []any, err error) {
+		if values, err = exec.intercept(values, injected, NewByteScannerReader(nil)); err != nil {
+			return
+		}
+		out = formatOut(values)
+		return
+	}
+	return
+}
+
 func (exec *Caller) call(args ByteScannerReader) (out []reflect.Value, err error) {
-	values, err := exec.decodeIn(args)
+	values, injected, stream, err := exec.decodeIn(args)
 	if err != nil {
 		return
 	}
+	if !stream.IsValid() {
+		return exec.intercept(values, injected, args)
+	}
+
+	// feed the stream argument
+	input := exec.input
+	if input == nil {
+		input = &Serializer{ByteScannerReader: args}
+		defer input.feeding.Wait()
+	}
+	drop := feed(input, stream, exec.hangup)
+	if out, err = exec.intercept(values, injected, args); err != nil {
+		drop()
+		return
+	}
+
+	// the input of a session is fed until its output ends
+	for i, v := range out {
+		if c, streamErr, ok := streamOf(v.Interface()); ok {
+			out[i] = reflect.ValueOf(&session{values: c, err: streamErr, drop: drop})
+			return
+		}
+	}
+	drop()
+	return
+}
+
+// feed decodes the values sent after the call into the stream argument until
+// the end of stream frame. Values received after drop are discarded, so the
+// connection is ready for the next request once the input is finished.
+// The hangup is called if the input breaks before the end of stream.
+func feed(input *Serializer, stream reflect.Value, hangup func()) (drop func()) {
+	in := input.valueDecoder()
+	done := make(chan struct{})
+	input.feeding.Add(1)
+	go func() {
+		defer input.feeding.Done()
+		defer stream.Close()
+		sel := []reflect.SelectCase{
+			{Dir: reflect.SelectSend, Chan: stream},
+			{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(done)},
+		}
+		dropping, next := false, false
+		for {
+			// the new line following a value is skipped before the next
+			// one, so the value is passed on without waiting for more input
+			if next {
+				skipNewline(input)
+			}
+			next = true
+			r := raw{}
+			if err := in.dec.Decode(&r); err != nil {
+				if hangup != nil {
+					hangup()
+				}
+				return
+			}
+			v := reflect.New(stream.Type().Elem())
+			rv := remoteValue{value: v.Interface()}
+			if err := in.decodeRaw(r.bytes, &rv); err != nil {
+				if rv.remote || errors.Is(err, ErrEndOfStream) {
+					skipNewline(input)
+					return
+				}
+				continue // drop malformed value
+			}
+			if dropping {
+				continue
+			}
+			sel[0].Send = v.Elem()
+			if i, _, _ := reflect.Select(sel); i == 1 {
+				dropping = true
+			}
+		}
+	}()
+	once := sync.Once{}
+	return func() { once.Do(func() { close(done) }) }
+}
+
+// recoverPanic converts a panic of the call into the error response and logs
+// it with the stack, which is also sent to the client in debug mode.
+func (exec *Caller) recoverPanic(out *[]reflect.Value, err *error) {
+	v := recover()
+	if v == nil {
+		return
+	}
+	stack := debug.Stack()
+	logger := exec.logger
+	if logger == nil {
+		logger = log.Default()
+	}
+	logger.Printf("panic in %s: %v\n%s", exec.name, v, stack)
+	e := &Error{Code: CodeInternal, Message: fmt.Sprint("panic: ", v), err: ErrPanic}
+	if exec.debug {
+		e.Data = string(stack)
+	}
+	*out, *err = nil, e
+}
+
+func (exec *Caller) invoke(values []reflect.Value, args ByteScannerReader) (out []reflect.Value, err error) {
 	values = exec.f.Call(values)
 	err = handleError(values)
 	if err != nil {
@@ -63,7 +220,12 @@ func (exec *Caller) call(args ByteScannerReader) (out []reflect.Value, err error
 	return
 }
 
-func (exec *Caller) decodeIn(args ByteScannerReader) (values []reflect.Value, err error) {
+// decodeIn decodes the arguments following the injected environment values,
+// the receive-only channel argument is returned as stream to feed with the
+// values sent after the call. Panics of custom decoders are recovered like
+// the ones of the call.
+func (exec *Caller) decodeIn(args ByteScannerReader) (values []reflect.Value, injected int, stream reflect.Value, err error) {
+	defer exec.recoverPanic(&values, &err)
 	var initial []reflect.Value
 	for _, a := range exec.env {
 		initial = append(initial, reflect.ValueOf(a))
@@ -84,15 +246,28 @@ func (exec *Caller) decodeIn(args ByteScannerReader) (values []reflect.Value, er
 
 	var decoded []any
 
+	injected = len(values)
 	for i := len(values); i < t.NumIn(); i++ {
 		at := t.In(i)
+		if at.Kind() == reflect.Chan && at.ChanDir() == reflect.RecvDir {
+			if stream.IsValid() {
+				err = ErrStreamArgsLimit
+				return
+			}
+			stream = reflect.MakeChan(reflect.ChanOf(reflect.BothDir, at.Elem()), 0)
+			values = append(values, stream)
+			continue
+		}
 		av := reflect.New(at)
 		values = append(values, av.Elem())
 		decoded = append(decoded, av.Interface())
 	}
 
 	if len(decoded) > 0 {
-		if err = exec.decoder.Decode(args, decoded); err != nil {
+		if err = exec.decoder.Decode(args, decoded); err != nil && !errors.Is(err, io.EOF) {
+			err = invalidArgs(err)
+		}
+		if err != nil {
 			return
 		}
 	}
@@ -118,8 +293,9 @@ var errorInterface = reflect.TypeOf((*error)(nil)).Elem()
 func (exec *Caller) runNested(values []reflect.Value, args ByteScannerReader) (r []reflect.Value, err error) {
 	for _, value := range values {
 		if value.Kind() == reflect.Func {
-			e := *(&exec)
+			e := *exec
 			e.f = value
+			e.interceptors = nil // nested functions are a part of the intercepted call
 			var rr []reflect.Value
 			if rr, err = e.call(args); err != nil {
 				return
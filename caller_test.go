@@ -197,3 +197,13 @@ func testFunc4(arg1 TestArg, arg2 TestArg3) (TestArg, TestArg3)       { return a
 func testFunc5(arg1 TestArgPos) TestArgPos                            { return arg1 }
 func testFunc6(arg1 TestArgPos, arg2 TestArg2) (TestArgPos, TestArg2) { return arg1, arg2 }
 func testFunc7(i int, b bool, s TestArg2) (int, bool, string)         { return i, b, s.S }
+
+func TestCaller_StreamArgs(t *testing.T) {
+	sum := func(base int, values <-chan int) int { return base }
+	_, err := NewCaller("sum").Func(sum).Bind(NewByteScannerReader(strings.NewReader("[1]\n")))
+	assert.ErrorIs(t, err, ErrTaggedStreamArgs)
+
+	twice := func(a, b <-chan int) {}
+	_, err = NewCaller("twice").Func(twice).Call(NewByteScannerReader(nil))
+	assert.ErrorIs(t, err, ErrStreamArgsLimit)
+}
@@ -0,0 +1,401 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const rpcPath = "github.com/cryptopunkscc/go-apphost-jrpc"
+const idPath = "github.com/cryptopunkscc/astrald/auth/id"
+
+type Config struct {
+	Dir     string // directory of the package declaring the interface
+	Type    string // interface type name
+	Client  string // client type name
+	Package string // output package name, if differs from the interface package
+	Output  string // output file, excluded from parsing
+}
+
+type generator struct {
+	Config
+	fset    *token.FileSet
+	src     string              // interface package name
+	path    string              // interface package import path, if the client is generated outside
+	imports map[string]string   // file imports by name
+	used    map[string]string   // imports used by the client
+	done    map[*ast.Ident]bool // qualified identifiers
+	buf     bytes.Buffer
+}
+
+// Generate returns formatted source of a client for the interface.
+func Generate(config Config) (src []byte, err error) {
+	g := &generator{Config: config, fset: token.NewFileSet(), used: map[string]string{}, done: map[*ast.Ident]bool{}}
+	iface, err := g.find()
+	if err != nil {
+		return
+	}
+	if g.Client == "" {
+		g.Client = lowerFirst(g.Type) + "Client"
+	}
+	if g.Package == "" {
+		g.Package = g.src
+	}
+	if g.Package != g.src {
+		if g.path, err = importPath(g.Dir); err != nil {
+			return
+		}
+		g.used[g.src] = g.path
+	}
+	g.used["rpc"] = rpcPath
+	if err = g.generate(iface); err != nil {
+		return
+	}
+	return g.format()
+}
+
+// find parses the package and looks up the interface.
+func (g *generator) find() (iface *ast.InterfaceType, err error) {
+	files, err := filepath.Glob(filepath.Join(g.Dir, "*.go"))
+	if err != nil {
+		return
+	}
+	for _, name := range files {
+		if strings.HasSuffix(name, "_test.go") || g.Output != "" && sameFile(name, g.Output) {
+			continue
+		}
+		var file *ast.File
+		if file, err = parser.ParseFile(g.fset, name, nil, parser.SkipObjectResolution); err != nil {
+			return
+		}
+		g.src = file.Name.Name
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				ts := spec.(*ast.TypeSpec)
+				if ts.Name.Name != g.Type {
+					continue
+				}
+				if iface, ok = ts.Type.(*ast.InterfaceType); !ok {
+					return nil, fmt.Errorf("%s is not an interface", g.Type)
+				}
+				g.imports = fileImports(file)
+				return
+			}
+		}
+	}
+	return nil, fmt.Errorf("interface %s not found in %s", g.Type, g.Dir)
+}
+
+func (g *generator) generate(iface *ast.InterfaceType) (err error) {
+	var methods bytes.Buffer
+	skipped := false
+	for _, field := range iface.Methods.List {
+		ft, ok := field.Type.(*ast.FuncType)
+		if !ok {
+			return fmt.Errorf("embedded interfaces are not supported: %s", g.expr(field.Type))
+		}
+		for _, name := range field.Names {
+			if !name.IsExported() {
+				continue
+			}
+			if strings.HasSuffix(name.Name, "Auth") {
+				skipped = true
+				continue
+			}
+			if err = g.method(&methods, name.Name, ft); err != nil {
+				return fmt.Errorf("%s.%s: %w", g.Type, name.Name, err)
+			}
+		}
+	}
+
+	// the client doesn't implement the interface without skipped methods
+	ret := g.qualify(g.Type)
+	if skipped {
+		ret = "*" + g.Client
+	}
+
+	fmt.Fprintf(&g.buf, "type %s struct {\n\tconn rpc.Conn\n}\n\n", g.Client)
+	fmt.Fprintf(&g.buf, "func New%s(conn rpc.Conn) %s {\n\treturn &%s{conn}\n}\n", upperFirst(g.Client), ret, g.Client)
+	g.buf.Write(methods.Bytes())
+	return
+}
+
+func (g *generator) method(w *bytes.Buffer, name string, ft *ast.FuncType) (err error) {
+	// params
+	var params, args []string
+	var stream, ctx string
+	i := 0
+	for _, field := range ft.Params.List {
+		typ := g.expr(field.Type)
+		names := field.Names
+		if len(names) == 0 {
+			names = []*ast.Ident{{Name: "_"}}
+		}
+		for _, n := range names {
+			arg := n.Name
+			switch arg {
+			case "_", "c", "rpc", "r", "err":
+				// clashes with the names used by the generated code
+				arg = "arg" + strconv.Itoa(i)
+			}
+			i++
+			params = append(params, arg+" "+typ)
+			switch ch, ok := field.Type.(*ast.ChanType); {
+			case ok && ch.Dir == ast.RECV:
+				if stream != "" {
+					return errors.New("only one stream argument is supported")
+				}
+				stream = arg
+			case g.isContext(field.Type):
+				ctx = arg
+			case !g.injected(field.Type):
+				args = append(args, arg)
+			}
+		}
+	}
+
+	// results
+	var results []ast.Expr
+	if ft.Results != nil {
+		for _, field := range ft.Results.List {
+			for n := max(1, len(field.Names)); n > 0; n-- {
+				results = append(results, field.Type)
+			}
+		}
+	}
+	withErr := len(results) > 0 && isError(results[len(results)-1])
+	if withErr {
+		results = results[:len(results)-1]
+	}
+	if len(results) > 1 {
+		return errors.New("more than one result besides error is not supported")
+	}
+
+	// the context of the caller aborts the call, Upload and Exchange have
+	// no context variants
+	call := fmt.Sprintf("(c.conn, %q", lowerFirst(name))
+	var withCtx string
+	if ctx != "" && stream == "" {
+		withCtx = "Context"
+		call = fmt.Sprintf("(%s, c.conn, %q", ctx, lowerFirst(name))
+	}
+	if stream != "" {
+		call += ", " + stream
+	}
+	for _, a := range args {
+		call += ", " + a
+	}
+	call += ")"
+
+	var sig, body string
+	switch {
+	case stream != "" && len(results) == 0 && withErr:
+		sig = "error"
+		body = "_, err := rpc.Upload[any]" + call + "\n\treturn err"
+	case stream != "" && len(results) == 0:
+		body = "_, _ = rpc.Upload[any]" + call
+	case len(results) == 0 && withErr:
+		sig = "error"
+		body = "return rpc.Command" + withCtx + call
+	case len(results) == 0:
+		body = "_ = rpc.Command" + withCtx + call
+	default:
+		var typ, fn string
+		switch ch, ok := results[0].(*ast.ChanType); {
+		case g.emitter(results[0]) != nil:
+			if stream != "" {
+				return errors.New("stream arguments with emitter results are not supported")
+			}
+			value := g.expr(g.emitter(results[0]))
+			typ = "*rpc.Stream[" + value + "]"
+			fn = "rpc.SubscribeStream" + withCtx + "[" + value + "]"
+		case ok:
+			if ch.Dir != ast.RECV {
+				return errors.New("only receive-only channels are supported")
+			}
+			typ = g.expr(results[0])
+			fn = "rpc.Subscribe" + withCtx + "[" + g.expr(ch.Value) + "]"
+			if stream != "" {
+				fn = "rpc.Exchange[" + g.expr(ch.Value) + "]"
+			}
+		default:
+			typ = g.expr(results[0])
+			fn = "rpc.Query" + withCtx + "[" + typ + "]"
+			if stream != "" {
+				fn = "rpc.Upload[" + typ + "]"
+			}
+		}
+		if withErr {
+			sig = "(" + typ + ", error)"
+			body = "return " + fn + call
+		} else {
+			sig = typ
+			body = "r, _ := " + fn + call + "\n\treturn r"
+		}
+	}
+	fmt.Fprintf(w, "\nfunc (c %s) %s(%s) %s {\n\t%s\n}\n", g.Client, name, strings.Join(params, ", "), sig, body)
+	return
+}
+
+// injected reports if the param is injected by the router instead of being sent.
+func (g *generator) injected(expr ast.Expr) bool {
+	return g.isContext(expr) || g.is(expr, idPath, "Identity")
+}
+
+// isContext reports if the param is context.Context.
+func (g *generator) isContext(expr ast.Expr) bool {
+	return g.is(expr, "context", "Context")
+}
+
+// is reports if the expression is the type of the imported package.
+func (g *generator) is(expr ast.Expr, path string, name string) bool {
+	sel, ok := expr.(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	x, ok := sel.X.(*ast.Ident)
+	return ok && g.imports[x.Name] == path && sel.Sel.Name == name
+}
+
+// emitter returns the value type of *rpc.Emitter result, or nil for other types.
+func (g *generator) emitter(expr ast.Expr) ast.Expr {
+	star, ok := expr.(*ast.StarExpr)
+	if !ok {
+		return nil
+	}
+	index, ok := star.X.(*ast.IndexExpr)
+	if !ok {
+		return nil
+	}
+	sel, ok := index.X.(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != "Emitter" {
+		return nil
+	}
+	if x, ok := sel.X.(*ast.Ident); !ok || g.imports[x.Name] != rpcPath {
+		return nil
+	}
+	return index.Index
+}
+
+// expr prints the type expression, qualifying and collecting used packages.
+func (g *generator) expr(expr ast.Expr) string {
+	g.rewrite(expr)
+	b := strings.Builder{}
+	_ = printer.Fprint(&b, g.fset, expr)
+	return b.String()
+}
+
+// rewrite qualifies local types if the client is generated in another package.
+func (g *generator) rewrite(node ast.Node) {
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.SelectorExpr:
+			if x, ok := n.X.(*ast.Ident); ok {
+				if path, ok := g.imports[x.Name]; ok {
+					g.used[x.Name] = path
+				}
+			}
+			return false
+		case *ast.Ident:
+			if !g.done[n] {
+				g.done[n] = true
+				n.Name = g.qualify(n.Name)
+			}
+		}
+		return true
+	})
+}
+
+func (g *generator) qualify(name string) string {
+	if g.path == "" || !ast.IsExported(name) {
+		return name
+	}
+	return g.src + "." + name
+}
+
+func (g *generator) format() (src []byte, err error) {
+	out := bytes.Buffer{}
+	fmt.Fprintf(&out, "// Code generated by jrpcgen. DO NOT EDIT.\n\npackage %s\n\nimport (\n", g.Package)
+	names := make([]string, 0, len(g.used))
+	for name := range g.used {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return g.used[names[i]] < g.used[names[j]] })
+	for _, name := range names {
+		path := g.used[name]
+		if name == filepath.Base(path) {
+			fmt.Fprintf(&out, "\t%q\n", path)
+		} else {
+			fmt.Fprintf(&out, "\t%s %q\n", name, path)
+		}
+	}
+	out.WriteString(")\n\n")
+	out.Write(g.buf.Bytes())
+	if src, err = format.Source(out.Bytes()); err != nil {
+		return nil, fmt.Errorf("format: %w\n%s", err, out.String())
+	}
+	return
+}
+
+func fileImports(file *ast.File) map[string]string {
+	imports := map[string]string{}
+	for _, spec := range file.Imports {
+		path, _ := strconv.Unquote(spec.Path.Value)
+		name := filepath.Base(path)
+		if path == rpcPath {
+			name = "jrpc"
+		}
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		imports[name] = path
+	}
+	return imports
+}
+
+func importPath(dir string) (string, error) {
+	out, err := exec.Command("go", "list", "-f", "{{.ImportPath}}", dir).Output()
+	if err != nil {
+		return "", fmt.Errorf("resolve import path of %s: %w", dir, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func isError(expr ast.Expr) bool {
+	i, ok := expr.(*ast.Ident)
+	return ok && i.Name == "error"
+}
+
+func sameFile(a, b string) bool {
+	sa, err1 := os.Stat(a)
+	sb, err2 := os.Stat(b)
+	return err1 == nil && err2 == nil && os.SameFile(sa, sb)
+}
+
+func lowerFirst(s string) string {
+	r := []rune(s)
+	r[0] = unicode.ToLower(r[0])
+	return string(r)
+}
+
+func upperFirst(s string) string {
+	r := []rune(s)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	src, err := Generate(Config{Dir: "testdata", Type: "Api"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, `// Code generated by jrpcgen. DO NOT EDIT.
+
+package testdata
+
+import (
+	"context"
+	"github.com/cryptopunkscc/astrald/auth/id"
+	rpc "github.com/cryptopunkscc/go-apphost-jrpc"
+	"time"
+)
+
+type apiClient struct {
+	conn rpc.Conn
+}
+
+func NewApiClient(conn rpc.Conn) *apiClient {
+	return &apiClient{conn}
+}
+
+func (c apiClient) Ping(ctx context.Context) {
+	_ = rpc.CommandContext(ctx, c.conn, "ping")
+}
+
+func (c apiClient) Whoami(ctx context.Context, caller id.Identity) (string, error) {
+	return rpc.QueryContext[string](ctx, c.conn, "whoami")
+}
+
+func (c apiClient) Wait(arg0 time.Duration) error {
+	return rpc.Command(c.conn, "wait", arg0)
+}
+
+func (c apiClient) Items(ctx context.Context, filter *Filter) (<-chan Item, error) {
+	return rpc.SubscribeContext[Item](ctx, c.conn, "items", filter)
+}
+
+func (c apiClient) Ticks(n int) *rpc.Stream[Item] {
+	r, _ := rpc.SubscribeStream[Item](c.conn, "ticks", n)
+	return r
+}
+
+func (c apiClient) Events(ctx context.Context) *rpc.Stream[Item] {
+	r, _ := rpc.SubscribeStreamContext[Item](ctx, c.conn, "events")
+	return r
+}
+
+func (c apiClient) Count() int {
+	r, _ := rpc.Query[int](c.conn, "count")
+	return r
+}
+
+func (c apiClient) Scale(arg0 int) int {
+	r, _ := rpc.Query[int](c.conn, "scale", arg0)
+	return r
+}
+
+func (c apiClient) Store(ctx context.Context, tag string, items <-chan Item) (int, error) {
+	return rpc.Upload[int](c.conn, "store", items, tag)
+}
+
+func (c apiClient) Chat(in <-chan string) <-chan string {
+	r, _ := rpc.Exchange[string](c.conn, "chat", in)
+	return r
+}
+
+func (c apiClient) Log(arg0 string, lines <-chan string) error {
+	_, err := rpc.Upload[any](c.conn, "log", lines, arg0)
+	return err
+}
+
+func (c apiClient) Admin() error {
+	return rpc.Command(c.conn, "admin")
+}
+`, string(src))
+}
@@ -0,0 +1,54 @@
+// Command jrpcgen generates a jrpc client for a Go interface.
+//
+// Usage:
+//
+//	//go:generate go run github.com/cryptopunkscc/go-apphost-jrpc/cmd/jrpcgen -type Api
+//
+// The client calls methods named after Router.Interface rules: the first rune
+// is lower-cased and methods with Auth suffix are skipped. Methods returning
+// a receive-only channel are called with Subscribe, methods returning error
+// only or nothing with Command, and others with Query.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("jrpcgen: ")
+
+	typeName := flag.String("type", "", "interface type name, required")
+	dir := flag.String("dir", ".", "directory of the package declaring the interface")
+	clientName := flag.String("client", "", "client type name, default <type>Client with lower-cased first rune")
+	output := flag.String("output", "", "output file, default <type>_client.go in the package directory")
+	pkg := flag.String("package", "", "output package name, default the interface package")
+	flag.Parse()
+
+	if *typeName == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *output == "" {
+		*output = filepath.Join(*dir, strings.ToLower(*typeName)+"_client.go")
+	}
+
+	src, err := Generate(Config{
+		Dir:     *dir,
+		Type:    *typeName,
+		Client:  *clientName,
+		Package: *pkg,
+		Output:  *output,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err = os.WriteFile(*output, src, 0644); err != nil {
+		log.Fatal(fmt.Errorf("write %s: %w", *output, err))
+	}
+}
@@ -0,0 +1,27 @@
+package testdata
+
+import (
+	"context"
+	"github.com/cryptopunkscc/astrald/auth/id"
+	"github.com/cryptopunkscc/go-apphost-jrpc"
+	"time"
+)
+
+type Api interface {
+	Ping(ctx context.Context)
+	Whoami(ctx context.Context, caller id.Identity) (string, error)
+	Wait(time.Duration) error
+	Items(ctx context.Context, filter *Filter) (<-chan Item, error)
+	Ticks(n int) *jrpc.Emitter[Item]
+	Events(ctx context.Context) *jrpc.Emitter[Item]
+	Count() int
+	Scale(r int) int
+	Store(ctx context.Context, tag string, items <-chan Item) (int, error)
+	Chat(in <-chan string) <-chan string
+	Log(err string, lines <-chan string) error
+	AdminAuth() bool
+	Admin() error
+}
+
+type Filter struct{ Name string }
+type Item struct{ Name string }
@@ -0,0 +1,99 @@
+package jrpc
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+)
+
+// Codec is a named encoding which can be negotiated on a connection.
+type Codec struct {
+	Name     string
+	Codecs   Codecs
+	Decoders []ArgsDecoder
+	// Binary codecs are sent in binary WebSocket frames.
+	Binary bool
+}
+
+var JsonCodec = Codec{Name: "json", Codecs: JsonCodecs}
+var CborCodec = Codec{Name: "cbor", Codecs: CborCodecs, Binary: true}
+var MsgpackCodec = Codec{Name: "msgpack", Codecs: MsgpackCodecs, Decoders: []ArgsDecoder{NewMsgpackArgsDecoder()}, Binary: true}
+
+var DefaultCodecs = []Codec{JsonCodec, CborCodec, MsgpackCodec}
+
+// CodecMethod is reserved for the codec handshake. It is recognized as the
+// first command of a connection only, followed by a JSON array of codec
+// names in order of client preference. The service responds with the name
+// of the picked codec and both sides switch to it.
+const CodecMethod = "codec"
+
+var ErrUnsupportedCodec = RegisterError("unsupported_codec", errors.New("unsupported codec"))
+
+// CodecConn is implemented by connections able to switch their codecs.
+type CodecConn interface {
+	Codecs(codecs Codecs)
+}
+
+// Negotiate performs the codec handshake on the flow and switches it to the codec picked by the service.
+// The connection must implement CodecConn.
+func Negotiate(conn Conn, codecs ...Codec) (codec Codec, err error) {
+	switcher, ok := conn.(CodecConn)
+	if !ok {
+		return codec, ErrUnsupportedCodec
+	}
+	var names []string
+	for _, c := range codecs {
+		names = append(names, c.Name)
+	}
+	if err = conn.Call(CodecMethod, names); err != nil {
+		return
+	}
+	name, err := Decode[string](conn)
+	if err != nil {
+		return
+	}
+	i := slices.IndexFunc(codecs, func(c Codec) bool { return c.Name == name })
+	if i < 0 {
+		return codec, ErrUnsupportedCodec
+	}
+	codec = codecs[i]
+	switcher.Codecs(codec.Codecs)
+	wsBinaryFrames(conn, codec)
+	return
+}
+
+func (r *Router) SupportedCodecs(codecs ...Codec) *Router {
+	r.supported = codecs
+	return r
+}
+
+// negotiate picks the first supported codec from the JSON array of names and
+// switches the connection to it after responding.
+func (r *Router) negotiate(args string) (err error) {
+	var names []string
+	if args != "" {
+		if err = json.Unmarshal([]byte(args), &names); err != nil {
+			return r.rpc.Encode(ErrMalformedRequest)
+		}
+	}
+	supported := r.supported
+	if supported == nil {
+		supported = DefaultCodecs
+	}
+	for _, name := range names {
+		i := slices.IndexFunc(supported, func(c Codec) bool { return c.Name == name })
+		if i < 0 {
+			continue
+		}
+		codec := supported[i]
+		if err = r.rpc.Encode(codec.Name); err != nil {
+			return
+		}
+		r.codecs = codec.Codecs
+		r.decoders = codec.Decoders
+		r.rpc.Codecs(codec.Codecs)
+		wsBinaryFrames(r.rpc, codec)
+		return
+	}
+	return r.rpc.Encode(ErrUnsupportedCodec)
+}
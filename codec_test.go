@@ -0,0 +1,55 @@
+package jrpc
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	router := NewRouter("test")
+	router.Func("sum", func(a, b int) int { return a + b })
+
+	for _, codec := range DefaultCodecs {
+		t.Run(codec.Name, func(t *testing.T) {
+			conn := NewFlow(serve(ctx, t, router))
+			c, err := Negotiate(conn, Codec{Name: "unknown"}, codec)
+			assert.NoError(t, err)
+			assert.Equal(t, codec.Name, c.Name)
+
+			r, err := Query[int](conn, "sum", 10, 2)
+			assert.NoError(t, err)
+			assert.Equal(t, 12, r)
+		})
+	}
+
+	t.Run("unsupported", func(t *testing.T) {
+		conn := NewFlow(serve(ctx, t, router))
+		_, err := Negotiate(conn, Codec{Name: "unknown"})
+		assert.EqualError(t, err, ErrUnsupportedCodec.Error())
+
+		r, err := Query[int](conn, "sum", 1, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, r)
+	})
+
+	t.Run("no handshake", func(t *testing.T) {
+		r, err := Query[int](NewFlow(serve(ctx, t, router)), "sum", 1, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, r)
+	})
+
+	t.Run("conn without codecs", func(t *testing.T) {
+		// conn implemented outside of the package
+		conn := struct{ Conn }{NewFlow(serve(ctx, t, router))}
+		_, err := Negotiate(conn, JsonCodec)
+		assert.ErrorIs(t, err, ErrUnsupportedCodec)
+
+		r, err := Query[int](conn, "sum", 1, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, r)
+	})
+}
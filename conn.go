@@ -1,6 +1,7 @@
 package jrpc
 
 import (
+	"context"
 	"errors"
 	"io"
 	"log"
@@ -25,6 +26,12 @@ func Call(conn Conn, name string, args ...any) (err error) {
 	return conn.Call(name, payload)
 }
 
+// CallContext is Call which sends the time left to the deadline of the
+// context, so the service cancels the call when it is exceeded.
+func CallContext(ctx context.Context, conn Conn, name string, args ...any) (err error) {
+	return Call(conn, timeoutPrefix(ctx)+name, args...)
+}
+
 func Decode[R any](conn Conn) (r R, err error) {
 	err = conn.Decode(&r)
 	return
@@ -54,29 +61,119 @@ func Query[R any](conn Conn, method string, args ...any) (r R, err error) {
 	if err = Call(conn, method, args...); err == nil {
 		r, err = Decode[R](conn)
 	}
-	if err != nil && err.Error() == "EOF" {
+	if errors.Is(err, io.EOF) {
 		err = nil
 	}
 	return
 }
 
 func Subscribe[R any](conn Conn, method string, args ...any) (c <-chan R, err error) {
+	s, err := SubscribeStream[R](conn, method, args...)
+	if err != nil {
+		return
+	}
+	return s.Values(), nil
+}
+
+// CommandContext is Command which aborts the call when the context is done.
+func CommandContext(ctx context.Context, conn Conn, method string, args ...any) (err error) {
+	conn = conn.Copy()
+	defer conn.Flush()
+	stop := abortOnDone(ctx, conn)
+	if err = CallContext(ctx, conn, method, args...); err == nil {
+		err = Await(conn)
+	}
+	if !stop() {
+		return ctx.Err()
+	}
+	if errors.Is(err, io.EOF) {
+		err = nil
+	}
+	return
+}
+
+// QueryContext is Query which aborts the call when the context is done.
+func QueryContext[R any](ctx context.Context, conn Conn, method string, args ...any) (r R, err error) {
 	conn = conn.Copy()
+	defer conn.Flush()
+	stop := abortOnDone(ctx, conn)
+	if err = CallContext(ctx, conn, method, args...); err == nil {
+		r, err = Decode[R](conn)
+	}
+	if !stop() {
+		return r, ctx.Err()
+	}
+	if errors.Is(err, io.EOF) {
+		err = nil
+	}
+	return
+}
+
+// AwaitContext is Await which closes the connection when the context is done.
+func AwaitContext(ctx context.Context, conn Conn) (err error) {
+	stop := abortOnDone(ctx, conn)
+	err = Await(conn)
+	if !stop() {
+		return ctx.Err()
+	}
+	return
+}
+
+// SubscribeContext is Subscribe which stops reading and closes the
+// connection when the context is done, so the service stops streaming.
+func SubscribeContext[R any](ctx context.Context, conn Conn, method string, args ...any) (c <-chan R, err error) {
+	s, err := SubscribeStreamContext[R](ctx, conn, method, args...)
+	if err != nil {
+		return
+	}
+	return s.Values(), nil
+}
+
+// abortOnDone closes the connection when the context is done, until stop is called.
+// Stop returns false if the connection was closed.
+func abortOnDone(ctx context.Context, conn Conn) (stop func() bool) {
+	return context.AfterFunc(ctx, func() { _ = conn.Close() })
+}
+
+// Upload calls the method and sends the values from the channel after the
+// call, followed by the end of stream frame. The values feed the receive-only
+// channel argument of the handler. Once the result is decoded the values
+// left in the channel are not sent anymore, so the service answering early
+// doesn't wait for the channel to be closed. Upload returns when the end of
+// stream is sent, or right away if the call failed, as the service may not
+// read the stream then.
+func Upload[R any, T any](conn Conn, method string, values <-chan T, args ...any) (r R, err error) {
+	conn = conn.Copy()
+	defer conn.Flush()
 	if err = Call(conn, method, args...); err != nil {
 		return
 	}
-	cc := make(chan R)
+	sent := make(chan struct{})
+	stop := make(chan struct{})
 	go func() {
-		defer close(cc)
-		defer conn.Flush()
-		var r R
+		defer close(sent)
 		for {
-			if err = conn.Decode(&r); err != nil {
+			select {
+			case v, ok := <-values:
+				if !ok {
+					_ = conn.Encode(EndOfStream)
+					return
+				}
+				if conn.Encode(v) != nil {
+					return
+				}
+			case <-stop:
+				_ = conn.Encode(EndOfStream)
 				return
 			}
-			cc <- r
 		}
 	}()
-	c = cc
+	if r, err = Decode[R](conn); errors.Is(err, io.EOF) {
+		err = nil
+	}
+	close(stop)
+	if err == nil {
+		<-sent
+	}
 	return
 }
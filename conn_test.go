@@ -0,0 +1,162 @@
+package jrpc
+
+import (
+	"context"
+	"github.com/cryptopunkscc/astrald/auth/id"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	stopped := make(chan struct{}, 1)
+	router := NewRouter("test")
+	router.Func("hang", func(ctx context.Context) int {
+		<-ctx.Done()
+		stopped <- struct{}{}
+		return 0
+	})
+	router.Func("sum", func(a, b int) int { return a + b })
+	router.Func("ticks", func(ctx context.Context) <-chan int {
+		c := make(chan int)
+		go func() {
+			defer close(c)
+			defer func() { stopped <- struct{}{} }()
+			for i := 0; ; i++ {
+				select {
+				case c <- i:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+		return c
+	})
+
+	t.Run("query deadline", func(t *testing.T) {
+		conn := NewFlow(serve(ctx, t, router))
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		_, err := QueryContext[int](ctx, conn, "sum", 1, 1)
+		assert.NoError(t, err)
+		_, err = QueryContext[int](ctx, conn, "hang")
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("command deadline", func(t *testing.T) {
+		conn := NewFlow(serve(ctx, t, router))
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		err := CommandContext(ctx, conn, "hang")
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("cancel multiplexed call", func(t *testing.T) {
+		conn := NewMuxFlow(serve(ctx, t, router))
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		_, err := QueryContext[int](ctx, conn, "hang")
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		<-stopped
+
+		// the connection is still usable
+		r, err := Query[int](conn, "sum", 1, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, r)
+	})
+
+	t.Run("cancel subscription", func(t *testing.T) {
+		conn := NewMuxFlow(serve(ctx, t, router))
+		ctx, cancel := context.WithCancel(ctx)
+		c, err := SubscribeContext[int](ctx, conn, "ticks")
+		assert.NoError(t, err)
+		assert.Equal(t, 0, <-c)
+		assert.Equal(t, 1, <-c)
+		cancel()
+		for range c {
+		}
+		<-stopped
+	})
+
+	t.Run("cancel request while querying", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		dialed := make(chan net.Conn, 1)
+		conn := NewRequest(id.Anyone, "test").(*Request)
+		conn.dialer = testDialer(func(identity id.Identity, query string) (io.ReadWriteCloser, error) {
+			<-ctx.Done()
+			server, client := net.Pipe()
+			dialed <- server
+			return client, nil
+		})
+		_, err := QueryContext[int](ctx, conn, "sum", 1, 2)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		// the stream queried after the context was done is closed
+		_, err = (<-dialed).Read(make([]byte, 1))
+		assert.ErrorIs(t, err, io.EOF)
+	})
+}
+
+func TestUpload(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	router := NewRouter("test")
+	router.Func("sum", func(base int, values <-chan int) int {
+		for v := range values {
+			base += v
+		}
+		return base
+	})
+	router.Func("first", func(values <-chan int) int {
+		return <-values
+	})
+	router.Func("secret", func(values <-chan int) int { return 0 })
+	router.Func("secret!", func() bool { return false })
+
+	conn := NewFlow(serve(ctx, t, router))
+
+	values := func(n int) <-chan int {
+		c := make(chan int)
+		go func() {
+			defer close(c)
+			for i := 1; i <= n; i++ {
+				c <- i
+			}
+		}()
+		return c
+	}
+
+	r, err := Upload[int](conn, "sum", values(4), 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 20, r)
+
+	// values not read by the handler are dropped
+	r, err = Upload[int](conn, "first", values(3))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, r)
+
+	r, err = Upload[int](conn, "sum", values(0), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, r)
+
+	// the values left after the result are not sent
+	endless := make(chan int, 1)
+	endless <- 1
+	r, err = Upload[int](conn, "first", endless)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, r)
+
+	r, err = Upload[int](conn, "sum", values(2), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, r)
+
+	// the upload rejected by the service doesn't wait for the values
+	_, err = Upload[int](NewFlow(serve(ctx, t, router)), "secret", make(chan int))
+	assert.ErrorIs(t, err, ErrUnauthorized)
+}
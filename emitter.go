@@ -0,0 +1,86 @@
+package jrpc
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+)
+
+// Emitter is a handler result streaming values to the client. Unlike a plain
+// channel, the stream is finished with an explicit frame: the end of stream
+// frame if the emitter was closed without error, or the failure otherwise.
+type Emitter[T any] struct {
+	c    chan T
+	err  error
+	once sync.Once
+}
+
+func NewEmitter[T any](size int) *Emitter[T] {
+	return &Emitter[T]{c: make(chan T, size)}
+}
+
+// C returns the channel of values to send.
+func (e *Emitter[T]) C() chan<- T {
+	return e.c
+}
+
+// Close finishes the stream with the error, or the end of stream frame if the error is nil.
+func (e *Emitter[T]) Close(err error) {
+	e.once.Do(func() {
+		e.err = err
+		close(e.c)
+	})
+}
+
+func (e *Emitter[T]) stream() (reflect.Value, func() error) {
+	return reflect.ValueOf(e.c), func() error { return e.err }
+}
+
+// streamer is a stream result which reports the error after its channel is closed.
+type streamer interface {
+	stream() (values reflect.Value, err func() error)
+}
+
+// session is the output stream of a call with stream argument, the input is
+// dropped when the output ends.
+type session struct {
+	values reflect.Value
+	err    func() error
+	drop   func()
+}
+
+func (s *session) stream() (reflect.Value, func() error) {
+	return s.values, func() error {
+		s.drop()
+		if s.err != nil {
+			return s.err()
+		}
+		return nil
+	}
+}
+
+// streamOf returns the channel of the stream result and the source of its
+// final error, which is nil for plain channels.
+func streamOf(result any) (values reflect.Value, err func() error, ok bool) {
+	if s, ok := result.(streamer); ok {
+		values, err = s.stream()
+		return values, err, true
+	}
+	values = reflect.ValueOf(result)
+	return values, nil, values.Kind() == reflect.Chan
+}
+
+// EndOfStream is the frame sent after the last value of an Emitter.
+var EndOfStream = struct {
+	Stream string `json:"stream"`
+}{Stream: "end"}
+
+// ErrEndOfStream is returned by Decode when the end of stream frame is received.
+var ErrEndOfStream = fmt.Errorf("end of stream: %w", io.EOF)
+
+// frame is a control message received in place of a value.
+type frame struct {
+	Failure
+	Stream string `json:"stream,omitempty"`
+}
@@ -0,0 +1,115 @@
+package jrpc
+
+import (
+	"errors"
+	"sync"
+)
+
+// Error is an error with a code and optional structured data, which is sent
+// to the client along with the message.
+type Error struct {
+	Code    int
+	Message string
+	Data    any
+	err     error
+}
+
+const (
+	CodeMalformedRequest = 400
+	CodeUnauthorized     = 401
+	CodeNotFound         = 404
+	CodeInternal         = 500
+)
+
+func NewError(code int, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+// WithData returns a copy of the error carrying the data.
+func (e *Error) WithData(data any) *Error {
+	ee := *e
+	ee.Data = data
+	return &ee
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+type sentinel struct {
+	id  string
+	err error
+}
+
+var sentinels struct {
+	sync.RWMutex
+	list []sentinel
+}
+
+// RegisterError registers the sentinel error under a stable identifier. Encoded
+// errors matching the sentinel are tagged with the identifier and decoded back
+// as the same sentinel value, so clients can match them with errors.Is.
+func RegisterError(id string, err error) error {
+	sentinels.Lock()
+	defer sentinels.Unlock()
+	for i, s := range sentinels.list {
+		if s.id == id {
+			sentinels.list[i].err = err
+			return err
+		}
+	}
+	sentinels.list = append(sentinels.list, sentinel{id: id, err: err})
+	return err
+}
+
+func sentinelId(err error) string {
+	sentinels.RLock()
+	defer sentinels.RUnlock()
+	for _, s := range sentinels.list {
+		if errors.Is(err, s.err) {
+			return s.id
+		}
+	}
+	return ""
+}
+
+func sentinelError(id string) error {
+	sentinels.RLock()
+	defer sentinels.RUnlock()
+	for _, s := range sentinels.list {
+		if s.id == id {
+			return s.err
+		}
+	}
+	return nil
+}
+
+// newFailure converts the error into its wire form.
+func newFailure(err error) (f Failure) {
+	f.Error = err.Error()
+	f.Type = sentinelId(err)
+	var e *Error
+	if errors.As(err, &e) {
+		f.Code = e.Code
+		f.Data = e.Data
+	}
+	return
+}
+
+// err converts the failure back into an error. The old form carrying the message only
+// is decoded as a plain error, or the registered sentinel.
+func (f Failure) err() error {
+	s := sentinelError(f.Type)
+	switch {
+	case f.Code != 0 || f.Data != nil:
+	case s == nil:
+		return errors.New(f.Error)
+	case s.Error() == f.Error:
+		return s
+	}
+	return &Error{Code: f.Code, Message: f.Error, Data: f.Data, err: s}
+}
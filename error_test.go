@@ -0,0 +1,78 @@
+package jrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestError(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	router := NewRouter("test")
+	router.Func("find", func(n int) (string, error) {
+		err := NewError(CodeNotFound, "not found").WithData(fmt.Sprint(n))
+		return "", fmt.Errorf("find %d: %w", n, err)
+	})
+	router.Func("fail", func() error { return errors.New("failed") })
+
+	for _, codec := range DefaultCodecs {
+		t.Run(codec.Name, func(t *testing.T) {
+			conn := NewFlow(serve(ctx, t, router))
+			if _, err := Negotiate(conn, codec); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := Query[string](conn, "find", 1)
+			var e *Error
+			if assert.ErrorAs(t, err, &e) {
+				assert.Equal(t, CodeNotFound, e.Code)
+				assert.Equal(t, "find 1: not found", e.Message)
+				assert.Equal(t, "1", e.Data)
+			}
+
+			_, err = Query[string](conn, "fail")
+			assert.False(t, errors.As(err, &e))
+			assert.EqualError(t, err, "failed")
+		})
+	}
+}
+
+var errTestSentinel = RegisterError("test_sentinel", errors.New("test sentinel"))
+
+func TestRegisterError(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	router := NewRouter("test")
+	router.Func("sentinel", func() error { return errTestSentinel })
+	router.Func("wrapped", func() error { return fmt.Errorf("wrapped: %w", errTestSentinel) })
+	router.Func("coded", func() error { return &Error{Code: CodeNotFound, Message: "coded", err: errTestSentinel} })
+	router.Func("secret", func() string { return "secret" })
+	router.Func("secret!", func() bool { return false })
+
+	conn := NewFlow(serve(ctx, t, router))
+
+	err := Command(conn, "sentinel")
+	assert.Equal(t, errTestSentinel, err)
+
+	err = Command(conn, "wrapped")
+	assert.ErrorIs(t, err, errTestSentinel)
+	assert.EqualError(t, err, "wrapped: test sentinel")
+
+	err = Command(conn, "coded")
+	assert.ErrorIs(t, err, errTestSentinel)
+	var e *Error
+	if assert.ErrorAs(t, err, &e) {
+		assert.Equal(t, CodeNotFound, e.Code)
+	}
+
+	err = Command(conn, "secret")
+	assert.ErrorIs(t, err, ErrUnauthorized)
+
+	err = Command(conn, "unknown[]")
+	assert.ErrorIs(t, err, ErrMalformedRequest)
+}
@@ -1,5 +1,7 @@
 package main
 
+//go:generate go run github.com/cryptopunkscc/go-apphost-jrpc/cmd/jrpcgen -type Api -client apiClient
+
 type Api interface {
 	Method(b bool, i int, s string)
 	Method1(bool) error
@@ -0,0 +1,39 @@
+// Code generated by jrpcgen. DO NOT EDIT.
+
+package main
+
+import (
+	rpc "github.com/cryptopunkscc/go-apphost-jrpc"
+)
+
+type apiClient struct {
+	conn rpc.Conn
+}
+
+func NewApiClient(conn rpc.Conn) Api {
+	return &apiClient{conn}
+}
+
+func (c apiClient) Method(b bool, i int, s string) {
+	_ = rpc.Command(c.conn, "method", b, i, s)
+}
+
+func (c apiClient) Method1(arg0 bool) error {
+	return rpc.Command(c.conn, "method1", arg0)
+}
+
+func (c apiClient) Method2(arg *Arg) (Arg, error) {
+	return rpc.Query[Arg](c.conn, "method2", arg)
+}
+
+func (c apiClient) Method2S() (string, error) {
+	return rpc.Query[string](c.conn, "method2S")
+}
+
+func (c apiClient) Method2B() (bool, error) {
+	return rpc.Query[bool](c.conn, "method2B")
+}
+
+func (c apiClient) MethodC() (<-chan Arg, error) {
+	return rpc.Subscribe[Arg](c.conn, "methodC")
+}
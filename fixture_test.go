@@ -0,0 +1,27 @@
+package jrpc
+
+import (
+	"context"
+	"github.com/cryptopunkscc/astrald/auth/id"
+	"io"
+	"net"
+	"testing"
+)
+
+// handler is a Router or App serving connections in tests.
+type handler interface {
+	Handle(ctx context.Context, query any, remoteId id.Identity, conn io.ReadWriteCloser) error
+}
+
+// serve handles a new in-memory connection with the handler and returns the
+// client end of it, which is closed when the test finishes. The connection
+// is synchronous, like jrpctest.Pipe it is meant for tests only.
+func serve(ctx context.Context, t testing.TB, h handler) net.Conn {
+	server, client := net.Pipe()
+	t.Cleanup(func() { _ = client.Close() })
+	go func() {
+		defer server.Close()
+		_ = h.Handle(ctx, "test", id.Anyone, server)
+	}()
+	return client
+}
@@ -2,7 +2,6 @@ package jrpc
 
 import (
 	"github.com/cryptopunkscc/astrald/auth/id"
-	"github.com/cryptopunkscc/astrald/lib/astral"
 	"io"
 )
 
@@ -15,7 +14,7 @@ func NewFlow(conn io.ReadWriteCloser) *Flow {
 }
 
 func QueryFlow(identity id.Identity, service string) (s Conn, err error) {
-	query, err := astral.Query(identity, service)
+	query, err := DefaultApphost.Query(identity, service)
 	if err != nil {
 		return
 	}
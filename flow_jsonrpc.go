@@ -0,0 +1,94 @@
+package jrpc
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+	"sync/atomic"
+)
+
+// JsonRpcFlow is a Conn speaking JSON-RPC 2.0 protocol.
+type JsonRpcFlow struct {
+	*Flow
+	lastId atomic.Uint64
+}
+
+func NewJsonRpcFlow(conn io.ReadWriteCloser) *JsonRpcFlow {
+	return &JsonRpcFlow{Flow: NewFlow(conn)}
+}
+
+func (conn *JsonRpcFlow) Copy() Conn {
+	return conn
+}
+
+// Call sends the request with the next ID, the response can be read with Decode.
+func (conn *JsonRpcFlow) Call(method string, value any) (err error) {
+	req, err := conn.Request(method, value)
+	if err != nil {
+		return
+	}
+	return conn.Encode(req)
+}
+
+// Notify sends the request without ID, the service sends no response.
+func (conn *JsonRpcFlow) Notify(method string, value any) (err error) {
+	req, err := NewJsonRpcNotification(method, value)
+	if err != nil {
+		return
+	}
+	return conn.Encode(req)
+}
+
+// Request creates a request with the next ID, to be sent in a Batch.
+func (conn *JsonRpcFlow) Request(method string, value any) (req JsonRpcRequest, err error) {
+	if req, err = NewJsonRpcNotification(method, value); err != nil {
+		return
+	}
+	req.ID = json.RawMessage(strconv.FormatUint(conn.lastId.Add(1), 10))
+	return
+}
+
+// Batch sends the requests at once and returns the responses in order they were received.
+func (conn *JsonRpcFlow) Batch(requests ...JsonRpcRequest) (responses []JsonRpcResponse, err error) {
+	if err = conn.Encode(requests); err != nil {
+		return
+	}
+	for _, req := range requests {
+		if req.ID != nil {
+			err = conn.dec.Decode(&responses)
+			for _, res := range responses {
+				if res.Error != nil {
+					res.Error.restoreSentinel()
+				}
+			}
+			return
+		}
+	}
+	return
+}
+
+// Decode reads the next response and unmarshal its result into the value.
+func (conn *JsonRpcFlow) Decode(value any) (err error) {
+	res := JsonRpcResponse{}
+	if err = conn.dec.Decode(&res); err != nil {
+		return
+	}
+	value, remote := unwrapRemote(value)
+	if res.Error != nil {
+		*remote = true
+		res.Error.restoreSentinel()
+		return res.Error
+	}
+	if value == nil {
+		return
+	}
+	return json.Unmarshal(res.Result, value)
+}
+
+func NewJsonRpcNotification(method string, value any) (req JsonRpcRequest, err error) {
+	req = JsonRpcRequest{JsonRpc: JsonRpcVersion, Method: method}
+	if value != nil {
+		req.Params, err = json.Marshal(value)
+	}
+	return
+}
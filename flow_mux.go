@@ -0,0 +1,166 @@
+package jrpc
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// MuxFlow multiplexes concurrent calls over a single connection. Each call
+// made through a Copy is sent with a request ID and receives the responses
+// tagged with it, so Query, Command and Subscribe can run concurrently.
+type MuxFlow struct {
+	*Flow
+	wmu    sync.Mutex
+	mu     sync.Mutex
+	lastId uint64
+	calls  map[uint64]*muxCall
+	last   *muxCall
+	err    error
+}
+
+func NewMuxFlow(conn io.ReadWriteCloser) *MuxFlow {
+	flow, ok := conn.(*Flow)
+	if !ok {
+		flow = NewFlow(conn)
+	}
+	m := &MuxFlow{Flow: flow, calls: map[uint64]*muxCall{}}
+	go m.read()
+	return m
+}
+
+// Copy returns a Conn for a single call with the next request ID.
+func (m *MuxFlow) Copy() Conn {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lastId++
+	c := &muxCall{MuxFlow: m, id: m.lastId, ready: make(chan struct{}, 1), err: m.err}
+	m.calls[c.id] = c
+	return c
+}
+
+// Call starts a new call, the responses can be read with Decode. Calling
+// again releases the previous call, so concurrent calls need a Copy each.
+func (m *MuxFlow) Call(method string, value any) (err error) {
+	c := m.Copy().(*muxCall)
+	m.mu.Lock()
+	last := m.last
+	m.last = c
+	m.mu.Unlock()
+	if last != nil {
+		last.Flush()
+	}
+	return c.Call(method, value)
+}
+
+// Decode reads the next response of the last call started with Call.
+func (m *MuxFlow) Decode(value any) (err error) {
+	m.mu.Lock()
+	last := m.last
+	m.mu.Unlock()
+	if last == nil {
+		return io.EOF
+	}
+	return last.Decode(value)
+}
+
+func (m *MuxFlow) Encode(value any) (err error) {
+	m.wmu.Lock()
+	defer m.wmu.Unlock()
+	return m.Flow.Encode(value)
+}
+
+func (m *MuxFlow) read() {
+	for {
+		r := raw{}
+		err := m.dec.Decode(&r)
+		if err != nil {
+			m.mu.Lock()
+			m.err = err
+			for _, c := range m.calls {
+				c.push(nil, err)
+			}
+			m.mu.Unlock()
+			return
+		}
+		msg := taggedMessage{}
+		if err = m.unmarshal(r.bytes, &msg); err != nil || msg.ID == 0 {
+			continue // drop untagged response
+		}
+		m.mu.Lock()
+		c := m.calls[msg.ID]
+		m.mu.Unlock()
+		if c != nil {
+			c.push(&msg, nil)
+		}
+	}
+}
+
+type muxCall struct {
+	*MuxFlow
+	id    uint64
+	mu    sync.Mutex
+	queue []*taggedMessage
+	ready chan struct{}
+	err   error
+}
+
+func (c *muxCall) push(msg *taggedMessage, err error) {
+	c.mu.Lock()
+	if msg != nil {
+		c.queue = append(c.queue, msg)
+	}
+	if err != nil {
+		c.err = err
+	}
+	c.mu.Unlock()
+	select {
+	case c.ready <- struct{}{}:
+	default:
+	}
+}
+
+func (c *muxCall) Call(method string, value any) (err error) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	return c.Flow.Call(fmt.Sprintf("#%d:%s", c.id, method), value)
+}
+
+// Decode reads the next response of the call, io.EOF is returned at the end of stream.
+func (c *muxCall) Decode(value any) (err error) {
+	for {
+		c.mu.Lock()
+		if len(c.queue) > 0 {
+			msg := c.queue[0]
+			c.queue = c.queue[1:]
+			c.mu.Unlock()
+			if msg.Done {
+				return io.EOF
+			}
+			return c.decodeRaw(msg.Value.bytes, value)
+		}
+		err = c.err
+		c.mu.Unlock()
+		if err != nil {
+			return
+		}
+		<-c.ready
+	}
+}
+
+// Flush releases the request ID, the following responses are dropped.
+func (c *muxCall) Flush() {
+	c.MuxFlow.mu.Lock()
+	defer c.MuxFlow.mu.Unlock()
+	delete(c.calls, c.id)
+}
+
+// Close cancels the call on the service and releases the request ID,
+// the connection stays open for other calls.
+func (c *muxCall) Close() (err error) {
+	c.Flush()
+	c.push(nil, io.ErrClosedPipe)
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	return c.Flow.Call(fmt.Sprintf("#%d:", c.id), nil)
+}
@@ -0,0 +1,91 @@
+package jrpc
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"sync"
+	"testing"
+)
+
+func TestMuxFlow(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	release := make(chan struct{})
+	router := NewRouter("test")
+	router.Func("sum", func(a, b int) int { return a + b })
+	router.Func("wait", func() string {
+		<-release
+		return "released"
+	})
+	router.Func("count", func(n int) <-chan int {
+		c := make(chan int)
+		go func() {
+			defer close(c)
+			for i := 0; i < n; i++ {
+				c <- i
+			}
+		}()
+		return c
+	})
+	router.Func("end", func() error { return io.EOF })
+	router.Func("secret", func() string { return "secret" })
+	router.Func("secret!", func() bool { return false })
+
+	conn := NewMuxFlow(serve(ctx, t, router))
+
+	// blocked call doesn't block others
+	var waited string
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		waited, _ = Query[string](conn, "wait")
+	}()
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			r, err := Query[int](conn, "sum", i, i)
+			assert.NoError(t, err)
+			assert.Equal(t, 2*i, r)
+		}(i)
+	}
+
+	stream := conn.Copy()
+	assert.NoError(t, Call(stream, "count", 3))
+	var values []int
+	for {
+		var v int
+		if err := stream.Decode(&v); err != nil {
+			assert.ErrorIs(t, err, io.EOF)
+			break
+		}
+		values = append(values, v)
+	}
+	stream.Flush()
+	assert.Equal(t, []int{0, 1, 2}, values)
+
+	assert.NoError(t, Command(conn, "end"))
+	assert.ErrorIs(t, Command(conn, "secret"), ErrUnauthorized)
+	assert.ErrorIs(t, Command(conn, "unknown[]"), ErrMalformedRequest)
+
+	close(release)
+	wg.Wait()
+	assert.Equal(t, "released", waited)
+
+	// concurrent calls made on the flow itself replace the last call
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			assert.NoError(t, Call(conn, "sum", i, i))
+		}(i)
+	}
+	wg.Wait()
+	r, err := Decode[int](conn)
+	assert.NoError(t, err)
+	assert.Zero(t, r%2)
+}
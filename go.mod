@@ -4,8 +4,10 @@ go 1.21
 
 require (
 	github.com/cryptopunkscc/astrald v0.0.0-20240220164229-d072469516dc
+	github.com/fxamacker/cbor/v2 v2.7.0
 	github.com/leaanthony/clir v1.6.0
 	github.com/stretchr/testify v1.9.0
+	github.com/vmihailenco/msgpack/v5 v5.4.1
 )
 
 require (
@@ -24,6 +26,8 @@ require (
 	github.com/mattn/go-sqlite3 v1.14.17 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/remyoudompheng/bigfft v0.0.0-20230129092748-24d4a6f8daec // indirect
+	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
+	github.com/x448/float16 v0.8.4 // indirect
 	golang.org/x/crypto v0.18.0 // indirect
 	golang.org/x/sys v0.16.0 // indirect
 	gopkg.in/yaml.v2 v2.4.0 // indirect
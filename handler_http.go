@@ -0,0 +1,157 @@
+package jrpc
+
+import (
+	"errors"
+	"github.com/cryptopunkscc/astrald/auth/id"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// HttpHandler maps POST /<port>/<method> requests with JSON array or object
+// body onto router calls. Channel results are streamed as newline delimited
+// JSON, or as server-sent events if the client accepts text/event-stream.
+type HttpHandler struct {
+	router *Router
+}
+
+// httpMaxBody limits the arguments sent in the request body, like the
+// payload of WebSocket messages.
+const httpMaxBody = wsMaxPayload
+
+func NewHttpHandler(router *Router) *HttpHandler {
+	return &HttpHandler{router: router}
+}
+
+func (h *HttpHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeHttpError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+
+	// setup
+	method, ok := httpMethod(h.router.port, req.URL.Path)
+	if !ok {
+		writeHttpError(w, http.StatusNotFound, ErrRejected)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, req.Body, httpMaxBody))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeHttpError(w, http.StatusRequestEntityTooLarge, err)
+		return
+	}
+	if err != nil {
+		writeHttpError(w, http.StatusBadRequest, err)
+		return
+	}
+	r := h.router.Query(h.router.port + "." + method)
+	if r.registry.IsEmpty() || r.args != "" {
+		writeHttpError(w, http.StatusNotFound, ErrRejected)
+		return
+	}
+	r.args = strings.TrimSpace(string(body))
+
+	// authorize
+	ctx := req.Context()
+	if !r.authorized(ctx, id.Anyone, req) {
+		writeHttpError(w, http.StatusForbidden, ErrUnauthorized)
+		return
+	}
+
+	// call
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	result, err := r.With(ctx, req, id.Anyone).Call()
+	result, err = deadline(ctx, result, err)
+	if err != nil {
+		writeHttpError(w, httpStatus(err), err)
+		return
+	}
+
+	// respond
+	if isReader(result) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		_ = copyReader(httpConn{w}, result[0].(io.Reader))
+		return
+	}
+	var conn io.Writer = w
+	switch {
+	case !isStream(result):
+		w.Header().Set("Content-Type", "application/json")
+	case strings.Contains(req.Header.Get("Accept"), "text/event-stream"):
+		w.Header().Set("Content-Type", "text/event-stream")
+		conn = sseWriter{w}
+	default:
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	}
+	r.Conn(httpConn{conn})
+	r.respond(ctx, nil, result...)
+}
+
+func httpMethod(port string, path string) (method string, ok bool) {
+	path = strings.TrimPrefix(path, "/")
+	if port != "" {
+		if path, ok = strings.CutPrefix(path, port); !ok {
+			return
+		}
+		if path != "" {
+			if path, ok = strings.CutPrefix(path, "/"); !ok {
+				return
+			}
+		}
+	}
+	return strings.ReplaceAll(path, "/", "."), true
+}
+
+func isStream(result []any) bool {
+	if len(result) != 1 {
+		return false
+	}
+	_, _, ok := streamOf(result[0])
+	return ok
+}
+
+// httpStatus returns the code of Error if it is a valid HTTP error status.
+func httpStatus(err error) int {
+	var e *Error
+	if errors.As(err, &e) && e.Code >= 400 && e.Code < 600 {
+		return e.Code
+	}
+	return http.StatusInternalServerError
+}
+
+func writeHttpError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	r := NewFlow(httpConn{w})
+	_ = r.Encode(err)
+}
+
+type httpConn struct{ io.Writer }
+
+func (c httpConn) Write(b []byte) (n int, err error) {
+	if n, err = c.Writer.Write(b); err == nil {
+		if f, ok := c.Writer.(http.Flusher); ok {
+			f.Flush()
+		}
+	}
+	return
+}
+
+func (c httpConn) Read([]byte) (int, error) { return 0, io.EOF }
+func (c httpConn) Close() error             { return nil }
+
+type sseWriter struct{ io.Writer }
+
+func (s sseWriter) Write(b []byte) (n int, err error) {
+	data := "data: " + strings.TrimSuffix(string(b), "\n") + "\n\n"
+	if _, err = io.WriteString(s.Writer, data); err != nil {
+		return
+	}
+	if f, ok := s.Writer.(http.Flusher); ok {
+		f.Flush()
+	}
+	return len(b), nil
+}
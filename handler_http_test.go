@@ -0,0 +1,107 @@
+package jrpc
+
+import (
+	"bufio"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHttpHandler(t *testing.T) {
+	router := NewRouter("test").With(context.Background())
+	router.Func("sum", func(a, b int) int { return a + b })
+	router.Func("arg", func(arg testRouterStruct) testRouterStruct { return arg })
+	router.Func("fail", func() error { return ErrMalformedRequest })
+	router.Func("count", func(n int) <-chan int {
+		c := make(chan int)
+		go func() {
+			defer close(c)
+			for i := 0; i < n; i++ {
+				c <- i
+			}
+		}()
+		return c
+	})
+	router.Func("secret", func() string { return "secret" })
+	router.Func("secret!", func(req *http.Request) bool { return req.Header.Get("Token") == "token" })
+	server := httptest.NewServer(NewHttpHandler(router))
+	t.Cleanup(server.Close)
+
+	post := func(path string, body string, header ...string) (*http.Response, string) {
+		req, err := http.NewRequest(http.MethodPost, server.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		b, err := io.ReadAll(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res, string(b)
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		body   string
+		header []string
+		status int
+		result string
+	}{
+		{name: "array args", path: "/test/sum", body: "[1, 2]", status: 200, result: "3\n"},
+		{name: "object arg", path: "/test/arg", body: `{"i":1,"s":"a"}`, status: 200, result: `{"i":1,"s":"a"}` + "\n"},
+		{name: "missing args", path: "/test/sum", status: 400, result: `{"error":"invalid arguments: unknown format","code":400,"type":"invalid_args"}` + "\n"},
+		{name: "error", path: "/test/fail", status: 500, result: `{"error":"malformed request","type":"malformed_request"}` + "\n"},
+		{name: "not found", path: "/test/sumx", status: 404, result: `{"error":"rejected","type":"rejected"}` + "\n"},
+		{name: "other port", path: "/test2/sum", status: 404, result: `{"error":"rejected","type":"rejected"}` + "\n"},
+		{name: "unauthorized", path: "/test/secret", status: 403, result: `{"error":"unauthorized","type":"unauthorized"}` + "\n"},
+		{name: "authorized", path: "/test/secret", header: []string{"Token", "token"}, status: 200, result: `"secret"` + "\n"},
+		{name: "ndjson stream", path: "/test/count", body: "[3]", status: 200, result: "0\n1\n2\n"},
+		{name: "sse stream", path: "/test/count", body: "[2]", header: []string{"Accept", "text/event-stream"}, status: 200, result: "data: 0\n\ndata: 1\n\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, body := post(tt.path, tt.body, tt.header...)
+			assert.Equal(t, tt.status, res.StatusCode)
+			assert.Equal(t, tt.result, body)
+		})
+	}
+
+	t.Run("method not allowed", func(t *testing.T) {
+		res, err := http.Get(server.URL + "/test/sum")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = res.Body.Close()
+		assert.Equal(t, http.StatusMethodNotAllowed, res.StatusCode)
+	})
+
+	t.Run("body too large", func(t *testing.T) {
+		res, body := post("/test/sum", "["+strings.Repeat(" ", httpMaxBody)+"]")
+		assert.Equal(t, http.StatusRequestEntityTooLarge, res.StatusCode)
+		assert.Equal(t, `{"error":"http: request body too large"}`+"\n", body)
+	})
+
+	t.Run("ndjson content type", func(t *testing.T) {
+		res, err := http.Post(server.URL+"/test/count", "application/json", strings.NewReader("[1]"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		assert.Equal(t, "application/x-ndjson", res.Header.Get("Content-Type"))
+		line, err := bufio.NewReader(res.Body).ReadString('\n')
+		assert.NoError(t, err)
+		assert.Equal(t, "0\n", line)
+	})
+}
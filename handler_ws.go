@@ -0,0 +1,196 @@
+package jrpc
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/cryptopunkscc/astrald/auth/id"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// WebSocketHandler serves the line protocol over WebSocket. The request path
+// /<port>/<method> selects the query, each received message is a command and
+// each response value is sent as a separate text message, or binary message
+// for binary codecs.
+type WebSocketHandler struct {
+	router      *Router
+	checkOrigin func(req *http.Request) bool
+}
+
+var ErrWsOrigin = errors.New("websocket origin not allowed")
+
+func NewWebSocketHandler(router *Router) *WebSocketHandler {
+	return &WebSocketHandler{router: router, checkOrigin: sameOrigin}
+}
+
+// CheckOrigin sets the function which tells if the Origin of the request is
+// allowed. By default only requests without Origin, which don't come from
+// browsers, and from the same origin are allowed, so other web pages cannot
+// open the socket with the credentials of the user.
+func (h *WebSocketHandler) CheckOrigin(check func(req *http.Request) bool) *WebSocketHandler {
+	h.checkOrigin = check
+	return h
+}
+
+func (h *WebSocketHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet ||
+		!headerContains(req.Header, "Connection", "upgrade") ||
+		!headerContains(req.Header, "Upgrade", "websocket") ||
+		req.Header.Get("Sec-WebSocket-Key") == "" {
+		writeHttpError(w, http.StatusBadRequest, errors.New("websocket upgrade required"))
+		return
+	}
+	if req.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		writeHttpError(w, http.StatusUpgradeRequired, errors.New("unsupported websocket version"))
+		return
+	}
+
+	if !h.checkOrigin(req) {
+		writeHttpError(w, http.StatusForbidden, ErrWsOrigin)
+		return
+	}
+
+	// setup
+	method, ok := httpMethod(h.router.port, req.URL.Path)
+	if !ok {
+		writeHttpError(w, http.StatusNotFound, ErrRejected)
+		return
+	}
+	query := h.router.port + "." + method
+	if args, err := url.QueryUnescape(req.URL.RawQuery); err == nil && args != "" {
+		query += "?" + args
+	}
+	r := h.router.Query(query)
+	if r.registry.IsEmpty() && method != "" {
+		writeHttpError(w, http.StatusNotFound, ErrRejected)
+		return
+	}
+
+	// authorize
+	ctx := req.Context()
+	if !r.authorized(ctx, id.Anyone, req) {
+		writeHttpError(w, http.StatusForbidden, ErrUnauthorized)
+		return
+	}
+
+	// accept
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		writeHttpError(w, http.StatusInternalServerError, errors.New("websocket not supported"))
+		return
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return
+	}
+	_, err = fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\n"+
+		"Upgrade: websocket\r\n"+
+		"Connection: Upgrade\r\n"+
+		"Sec-WebSocket-Accept: %s\r\n\r\n", wsAccept(req.Header.Get("Sec-WebSocket-Key")))
+	if err == nil {
+		err = rw.Flush()
+	}
+	if err != nil {
+		_ = conn.Close()
+		return
+	}
+	ws := newWsConn(conn, rw.Reader, false)
+	defer ws.Close()
+	_ = r.Handle(ctx, req, id.Anyone, ws)
+}
+
+func sameOrigin(req *http.Request) bool {
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, req.Host)
+}
+
+func headerContains(header http.Header, name string, value string) bool {
+	for _, v := range header.Values(name) {
+		for _, s := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(s), value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// DialWebSocket connects to a WebSocketHandler at the given ws:// or wss:// url.
+func DialWebSocket(rawUrl string) (c Conn, err error) {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return
+	}
+	host := u.Host
+	if u.Port() == "" {
+		port := "80"
+		if u.Scheme == "wss" {
+			port = "443"
+		}
+		host = net.JoinHostPort(u.Hostname(), port)
+	}
+	var conn net.Conn
+	switch u.Scheme {
+	case "ws":
+		conn, err = net.Dial("tcp", host)
+	case "wss":
+		conn, err = tls.Dial("tcp", host, &tls.Config{ServerName: u.Hostname()})
+	default:
+		err = fmt.Errorf("unsupported scheme %s", u.Scheme)
+	}
+	if err != nil {
+		return
+	}
+
+	nonce := make([]byte, 16)
+	if _, err = rand.Read(nonce); err != nil {
+		_ = conn.Close()
+		return
+	}
+	key := base64.StdEncoding.EncodeToString(nonce)
+	req := &http.Request{
+		Method: http.MethodGet,
+		URL:    &url.URL{Scheme: "http", Host: u.Host, Path: u.Path, RawQuery: u.RawQuery},
+		Host:   u.Host,
+		Header: http.Header{},
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	if err = req.Write(conn); err != nil {
+		_ = conn.Close()
+		return
+	}
+	reader := bufio.NewReader(conn)
+	res, err := http.ReadResponse(reader, req)
+	if err != nil {
+		_ = conn.Close()
+		return
+	}
+	if res.StatusCode != http.StatusSwitchingProtocols {
+		defer conn.Close()
+		f := Failure{}
+		if err = json.NewDecoder(res.Body).Decode(&f); err == nil && f.Error != "" {
+			return nil, f.err()
+		}
+		return nil, fmt.Errorf("websocket handshake failed: %s", res.Status)
+	}
+	if res.Header.Get("Sec-WebSocket-Accept") != wsAccept(key) {
+		_ = conn.Close()
+		return nil, errors.New("invalid websocket accept key")
+	}
+	return NewFlow(newWsConn(conn, reader, true)), nil
+}
@@ -0,0 +1,184 @@
+package jrpc
+
+import (
+	"context"
+	"github.com/cryptopunkscc/astrald/auth/id"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWebSocketHandler(t *testing.T) {
+	router := NewRouter("test").With(context.Background())
+	router.Func("sum", func(a, b int) int { return a + b })
+	router.Func("count", func(n int) <-chan int {
+		c := make(chan int)
+		go func() {
+			defer close(c)
+			for i := 0; i < n; i++ {
+				c <- i
+			}
+		}()
+		return c
+	})
+	router.Func("secret", func() string { return "secret" })
+	router.Func("secret!", func(req *http.Request) bool { return req.URL.Query().Has("token") })
+	server := httptest.NewServer(NewWebSocketHandler(router))
+	t.Cleanup(server.Close)
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	t.Run("query", func(t *testing.T) {
+		conn, err := DialWebSocket(url + "/test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		for i := 0; i < 3; i++ {
+			r, err := Query[int](conn, "sum", i, 2)
+			assert.NoError(t, err)
+			assert.Equal(t, i+2, r)
+		}
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		conn, err := DialWebSocket(url + "/test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		if err = Call(conn, "count", 3); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 3; i++ {
+			r, err := Decode[int](conn)
+			assert.NoError(t, err)
+			assert.Equal(t, i, r)
+		}
+	})
+
+	t.Run("message without newline", func(t *testing.T) {
+		conn, err := DialWebSocket(url + "/test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		if _, err = conn.Write([]byte("sum[2,2]")); err != nil {
+			t.Fatal(err)
+		}
+		r, err := Decode[int](conn)
+		assert.NoError(t, err)
+		assert.Equal(t, 4, r)
+	})
+
+	t.Run("query in url", func(t *testing.T) {
+		conn, err := DialWebSocket(url + "/test/sum?[1,1]")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		r, err := Decode[int](conn)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, r)
+	})
+
+	t.Run("unauthorized", func(t *testing.T) {
+		_, err := DialWebSocket(url + "/test/secret")
+		assert.EqualError(t, err, ErrUnauthorized.Error())
+
+		conn, err := DialWebSocket(url + "/test/secret?token")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		r, err := Decode[string](conn)
+		assert.NoError(t, err)
+		assert.Equal(t, "secret", r)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		_, err := DialWebSocket(url + "/test/unknown")
+		assert.EqualError(t, err, ErrRejected.Error())
+	})
+
+	upgrade := func(server *httptest.Server, origin string) int {
+		req, err := http.NewRequest(http.MethodGet, server.URL+"/test", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Upgrade", "websocket")
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+		req.Header.Set("Sec-WebSocket-Version", "13")
+		req.Header.Set("Origin", origin)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = res.Body.Close()
+		return res.StatusCode
+	}
+
+	t.Run("same origin", func(t *testing.T) {
+		assert.Equal(t, http.StatusSwitchingProtocols, upgrade(server, server.URL))
+	})
+
+	t.Run("cross origin", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, upgrade(server, "http://example.com"))
+	})
+
+	t.Run("allowed origin", func(t *testing.T) {
+		server := httptest.NewServer(NewWebSocketHandler(router).CheckOrigin(func(req *http.Request) bool {
+			return req.Header.Get("Origin") == "http://example.com"
+		}))
+		defer server.Close()
+		assert.Equal(t, http.StatusSwitchingProtocols, upgrade(server, "http://example.com"))
+		assert.Equal(t, http.StatusForbidden, upgrade(server, server.URL))
+	})
+}
+
+func TestWsConn(t *testing.T) {
+	t.Run("binary codec", func(t *testing.T) {
+		r := NewRouter("test")
+		r.Func("sum", func(a, b int) int { return a + b })
+		server, client := net.Pipe()
+		defer client.Close()
+		go func() {
+			defer server.Close()
+			_ = r.Handle(context.Background(), "test", id.Anyone, newWsConn(server, nil, false))
+		}()
+		conn := NewFlow(newWsConn(client, nil, true))
+		if _, err := Negotiate(conn, CborCodec); err != nil {
+			t.Fatal(err)
+		}
+		if err := Call(conn, "sum", 2, 2); err != nil {
+			t.Fatal(err)
+		}
+		h := make([]byte, 1)
+		if _, err := io.ReadFull(client, h); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, byte(0x80|wsBinary), h[0])
+	})
+
+	t.Run("unmasked client frame", func(t *testing.T) {
+		server, client := net.Pipe()
+		defer client.Close()
+		ws := newWsConn(server, nil, false)
+		go func() { _, _ = client.Write([]byte{0x80 | wsText, 3, 's', 'u', 'm'}) }()
+		read := make(chan error, 1)
+		go func() {
+			_, err := ws.Read(make([]byte, 8))
+			read <- err
+		}()
+		closing := make([]byte, 4)
+		if _, err := io.ReadFull(client, closing); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, []byte{0x80 | wsClose, 2, 0x03, 0xea}, closing)
+		assert.ErrorIs(t, <-read, ErrWsMasking)
+	})
+}
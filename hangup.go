@@ -0,0 +1,84 @@
+package jrpc
+
+import (
+	"io"
+	"sync"
+)
+
+// hangupReader reads the connection ahead in the background, so the router
+// learns that the client hung up while a call is running, also when the
+// call itself doesn't read the connection. Chunks are handed over one at a
+// time, so at most one chunk is read ahead.
+type hangupReader struct {
+	chunks chan []byte
+	done   chan struct{}
+	buf    []byte
+	mu     sync.Mutex
+	err    error
+	hangup func()
+}
+
+const hangupChunk = 4096
+
+func newHangupReader(reader io.Reader) *hangupReader {
+	h := &hangupReader{chunks: make(chan []byte), done: make(chan struct{})}
+	go h.readAhead(reader)
+	return h
+}
+
+func (h *hangupReader) readAhead(reader io.Reader) {
+	defer close(h.chunks)
+	for {
+		b := make([]byte, hangupChunk)
+		n, err := reader.Read(b)
+		if n > 0 {
+			select {
+			case h.chunks <- b[:n]:
+			case <-h.done:
+				return
+			}
+		}
+		if err != nil {
+			h.mu.Lock()
+			h.err = err
+			hangup := h.hangup
+			h.mu.Unlock()
+			if hangup != nil {
+				hangup()
+			}
+			return
+		}
+	}
+}
+
+func (h *hangupReader) Read(p []byte) (n int, err error) {
+	if len(h.buf) == 0 {
+		b, ok := <-h.chunks
+		if !ok {
+			h.mu.Lock()
+			defer h.mu.Unlock()
+			return 0, h.err
+		}
+		h.buf = b
+	}
+	n = copy(p, h.buf)
+	h.buf = h.buf[n:]
+	return
+}
+
+// watch sets the function called when the client hangs up, it is called
+// right away if the client already did. Nil stops watching.
+func (h *hangupReader) watch(hangup func()) {
+	h.mu.Lock()
+	h.hangup = hangup
+	failed := h.err != nil
+	h.mu.Unlock()
+	if failed && hangup != nil {
+		hangup()
+	}
+}
+
+// stop releases the reading goroutine once the connection is closed.
+func (h *hangupReader) stop() {
+	close(h.done)
+}
@@ -0,0 +1,112 @@
+package jrpc
+
+import (
+	"context"
+	"fmt"
+	"github.com/cryptopunkscc/astrald/auth/id"
+	"reflect"
+)
+
+// Invocation is the call of a method seen by interceptors.
+type Invocation struct {
+	Context  context.Context
+	Method   string
+	RemoteId id.Identity
+	// Args are the decoded arguments, without the injected environment.
+	// Interceptors can replace them with values of the same types.
+	Args []any
+}
+
+// Invoke calls the next interceptor or the method.
+type Invoke func() ([]any, error)
+
+// Interceptor runs around the call of a method. It can inspect or replace the
+// arguments and results, wrap stream results or return an error without
+// calling next.
+type Interceptor func(inv *Invocation, next Invoke) ([]any, error)
+
+// interceptors returns a copy of interceptors running the given ones first.
+func interceptors(first []Interceptor, then []Interceptor) []Interceptor {
+	if len(first) == 0 {
+		return then
+	}
+	return append(append([]Interceptor{}, first...), then...)
+}
+
+// intercept invokes the function through the interceptors.
+func (exec *Caller) intercept(values []reflect.Value, injected int, args ByteScannerReader) (out []reflect.Value, err error) {
+	defer exec.recoverPanic(&out, &err)
+	if len(exec.interceptors) == 0 {
+		return exec.invoke(values, args)
+	}
+
+	// take the context and identity from the environment, so they are known
+	// even if the function doesn't declare them
+	inv := &Invocation{Method: exec.name}
+	var hasId bool
+	for _, e := range exec.env {
+		switch e := e.(type) {
+		case context.Context:
+			if inv.Context == nil {
+				inv.Context = e
+			}
+		case id.Identity:
+			if !hasId {
+				inv.RemoteId, hasId = e, true
+			}
+		}
+	}
+	if inv.Context == nil {
+		inv.Context = context.Background()
+	}
+	for _, v := range values[injected:] {
+		inv.Args = append(inv.Args, v.Interface())
+	}
+
+	next := func() (result []any, err error) {
+		if err = exec.setArgs(values, injected, inv.Args); err != nil {
+			return
+		}
+		res, err := exec.invoke(values, args)
+		if err != nil {
+			return
+		}
+		return formatOut(res), nil
+	}
+	for i := len(exec.interceptors) - 1; i >= 0; i-- {
+		interceptor, invoke := exec.interceptors[i], next
+		next = func() ([]any, error) { return interceptor(inv, invoke) }
+	}
+
+	result, err := next()
+	out = make([]reflect.Value, len(result))
+	for i, r := range result {
+		out[i] = reflect.New(anyType).Elem()
+		if r != nil {
+			out[i].Set(reflect.ValueOf(r))
+		}
+	}
+	return
+}
+
+// setArgs sets the values of arguments following the injected ones.
+func (exec *Caller) setArgs(values []reflect.Value, injected int, args []any) error {
+	if len(args) != len(values)-injected {
+		return fmt.Errorf("%w: expected %d arguments, got %d", ErrMalformedRequest, len(values)-injected, len(args))
+	}
+	for i, a := range args {
+		t := exec.f.Type().In(injected + i)
+		if a == nil {
+			values[injected+i] = reflect.Zero(t)
+			continue
+		}
+		v := reflect.ValueOf(a)
+		if !v.Type().AssignableTo(t) {
+			return fmt.Errorf("%w: argument %d must be %s, got %T", ErrMalformedRequest, i, t, a)
+		}
+		values[injected+i] = v
+	}
+	return nil
+}
+
+var anyType = reflect.TypeOf((*any)(nil)).Elem()
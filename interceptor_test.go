@@ -0,0 +1,128 @@
+package jrpc
+
+import (
+	"context"
+	"github.com/cryptopunkscc/astrald/auth/id"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"sync"
+	"testing"
+)
+
+func TestRouter_Use(t *testing.T) {
+	type key struct{}
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), key{}, "handle"))
+	t.Cleanup(cancel)
+
+	mu := sync.Mutex{}
+	var calls []Invocation
+	var results []any
+
+	app := NewApp("test")
+	app.Func("sum", func(ctx context.Context, a, b int) int { return a + b })
+	app.Func("secret", func() string { return "secret" })
+	app.Func("double", func(a int) int { return a * 2 })
+	app.Func("count", func(n int) <-chan int {
+		c := make(chan int)
+		go func() {
+			defer close(c)
+			for i := 0; i < n; i++ {
+				c <- i
+			}
+		}()
+		return c
+	})
+	app.Use(func(inv *Invocation, next Invoke) (result []any, err error) {
+		mu.Lock()
+		calls = append(calls, *inv)
+		mu.Unlock()
+		result, err = next()
+		mu.Lock()
+		results = append(results, result...)
+		mu.Unlock()
+		return
+	}, func(inv *Invocation, next Invoke) ([]any, error) {
+		switch inv.Method {
+		case "secret":
+			return nil, ErrUnauthorized
+		case "sum":
+			inv.Args[1] = inv.Args[1].(int) * 10
+		case "count":
+			// double the stream values
+			result, err := next()
+			if err != nil {
+				return nil, err
+			}
+			in := result[0].(<-chan int)
+			out := make(chan int)
+			go func() {
+				defer close(out)
+				for i := range in {
+					out <- i * 2
+				}
+			}()
+			return []any{out}, nil
+		}
+		return next()
+	})
+
+	t.Run("args and results", func(t *testing.T) {
+		calls, results = nil, nil
+		sum, err := Query[int](NewFlow(serve(ctx, t, app)), "sum", 1, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, 21, sum)
+		assert.Len(t, calls, 1)
+		assert.Equal(t, "sum", calls[0].Method)
+		assert.Equal(t, id.Anyone, calls[0].RemoteId)
+		// the context of the call is derived from the context of Handle
+		assert.Equal(t, "handle", calls[0].Context.Value(key{}))
+		assert.Equal(t, []any{1, 20}, calls[0].Args)
+		assert.Equal(t, []any{21}, results)
+	})
+
+	t.Run("environment", func(t *testing.T) {
+		// the function declares neither the context nor the identity
+		remoteId, err := id.GenerateIdentity()
+		if err != nil {
+			t.Fatal(err)
+		}
+		server, client := net.Pipe()
+		t.Cleanup(func() { _ = client.Close() })
+		go func() {
+			defer server.Close()
+			_ = app.Handle(ctx, "test", remoteId, server)
+		}()
+
+		calls = nil
+		double, err := Query[int](NewFlow(client), "double", 2)
+		assert.NoError(t, err)
+		assert.Equal(t, 4, double)
+		assert.Len(t, calls, 1)
+		assert.True(t, remoteId.IsEqual(calls[0].RemoteId))
+		assert.Equal(t, "handle", calls[0].Context.Value(key{}))
+		assert.Equal(t, []any{2}, calls[0].Args)
+	})
+
+	t.Run("short circuit", func(t *testing.T) {
+		_, err := Query[string](NewFlow(serve(ctx, t, app)), "secret")
+		assert.ErrorIs(t, err, ErrUnauthorized)
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		c, err := Subscribe[int](NewFlow(serve(ctx, t, app)), "count", 3)
+		assert.NoError(t, err)
+		var values []int
+		for v := range c {
+			values = append(values, v)
+		}
+		assert.Equal(t, []int{0, 2, 4}, values)
+	})
+
+	t.Run("tagged", func(t *testing.T) {
+		calls = nil
+		sum, err := Query[int](NewMuxFlow(serve(ctx, t, app)), "sum", 1, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, 21, sum)
+		assert.Len(t, calls, 1)
+	})
+}
@@ -0,0 +1,121 @@
+package jrpctest
+
+import (
+	"errors"
+	"github.com/cryptopunkscc/astrald/auth/id"
+	"github.com/cryptopunkscc/astrald/mod/apphost/proto"
+	"github.com/cryptopunkscc/go-apphost-jrpc"
+	"io"
+	"sync"
+)
+
+// Network connects fake apphost nodes in memory.
+type Network struct {
+	mu    sync.Mutex
+	nodes []*Apphost
+}
+
+// Apphost is an in-memory fake of the astral apphost with its own identity.
+type Apphost struct {
+	jrpc.QueryMux
+	network  *Network
+	identity id.Identity
+}
+
+var _ jrpc.Apphost = &Apphost{}
+
+var ErrUnreachable = errors.New("unreachable")
+
+func NewNetwork() *Network {
+	return &Network{}
+}
+
+// New returns a fake apphost on a fresh network.
+func New() *Apphost {
+	return NewNetwork().Apphost()
+}
+
+func (n *Network) Apphost() *Apphost {
+	identity, err := id.GenerateIdentity()
+	if err != nil {
+		panic(err)
+	}
+	a := &Apphost{network: n, identity: identity}
+	n.mu.Lock()
+	n.nodes = append(n.nodes, a)
+	n.mu.Unlock()
+	return a
+}
+
+func (n *Network) find(identity id.Identity) *Apphost {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, a := range n.nodes {
+		if a.identity.IsEqual(identity) {
+			return a
+		}
+	}
+	return nil
+}
+
+func (a *Apphost) Identity() id.Identity {
+	return a.identity
+}
+
+// Query opens a connection to the route matching the query on the apphost
+// with the given identity. Zero identity targets the apphost itself.
+func (a *Apphost) Query(identity id.Identity, query string) (io.ReadWriteCloser, error) {
+	target := a
+	if !identity.IsEqual(id.Anyone) {
+		if target = a.network.find(identity); target == nil {
+			return nil, ErrUnreachable
+		}
+	}
+	local, remote := Pipe(target.identity, a.identity)
+	q := &incomingQuery{
+		query:    query,
+		remoteID: a.identity,
+		conn:     remote,
+		result:   make(chan error, 1),
+	}
+	if !target.Dispatch(q) {
+		return nil, proto.ErrRejected
+	}
+	if err := <-q.result; err != nil {
+		return nil, err
+	}
+	return local, nil
+}
+
+type incomingQuery struct {
+	query    string
+	remoteID id.Identity
+	conn     io.ReadWriteCloser
+	result   chan error
+	once     sync.Once
+}
+
+func (q *incomingQuery) Query() string {
+	return q.query
+}
+
+func (q *incomingQuery) RemoteIdentity() id.Identity {
+	return q.remoteID
+}
+
+func (q *incomingQuery) Accept() (conn io.ReadWriteCloser, err error) {
+	err = proto.ErrRejected
+	q.once.Do(func() {
+		conn, err = q.conn, nil
+		q.result <- nil
+	})
+	return
+}
+
+func (q *incomingQuery) Reject() error {
+	q.once.Do(func() {
+		_ = q.conn.Close()
+		q.result <- proto.ErrRejected
+	})
+	return nil
+}
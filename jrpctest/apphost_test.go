@@ -0,0 +1,72 @@
+package jrpctest
+
+import (
+	"context"
+	"github.com/cryptopunkscc/astrald/auth/id"
+	"github.com/cryptopunkscc/astrald/mod/apphost/proto"
+	"github.com/cryptopunkscc/go-apphost-jrpc"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestApphost_Query(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	network := NewNetwork()
+	service := network.Apphost()
+	client := network.Apphost()
+
+	router := jrpc.NewRouter("test").Listener(service).Routes("*")
+	router.Func("echo", func(s string) string { return s })
+	router.Func("caller", func(remoteId id.Identity) string { return remoteId.String() })
+	router.Func("secret", func() string { return "secret" })
+	router.Func("secret!", func() bool { return false })
+	if err := router.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("args", func(t *testing.T) {
+		conn, err := client.Query(service.Identity(), `test.echo?["a"]`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		flow := jrpc.NewFlow(conn)
+		s, err := jrpc.Decode[string](flow)
+		assert.NoError(t, err)
+		assert.Equal(t, "a", s)
+		assert.True(t, flow.RemoteIdentity().IsEqual(service.Identity()))
+	})
+
+	t.Run("remote identity", func(t *testing.T) {
+		conn, err := client.Query(service.Identity(), "test.caller")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		s, err := jrpc.Decode[string](jrpc.NewFlow(conn))
+		assert.NoError(t, err)
+		assert.Equal(t, client.Identity().String(), s)
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		_, err := client.Query(service.Identity(), "test.unknown")
+		assert.ErrorIs(t, err, proto.ErrRejected)
+	})
+
+	t.Run("unauthorized", func(t *testing.T) {
+		_, err := client.Query(service.Identity(), "test.secret")
+		assert.ErrorIs(t, err, proto.ErrRejected)
+	})
+
+	t.Run("not registered", func(t *testing.T) {
+		_, err := client.Query(service.Identity(), "other")
+		assert.ErrorIs(t, err, proto.ErrRejected)
+	})
+
+	t.Run("unreachable", func(t *testing.T) {
+		_, err := New().Query(service.Identity(), "test.echo")
+		assert.ErrorIs(t, err, ErrUnreachable)
+	})
+}
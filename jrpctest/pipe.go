@@ -0,0 +1,82 @@
+package jrpctest
+
+import (
+	"bytes"
+	"github.com/cryptopunkscc/astrald/auth/id"
+	"io"
+	"sync"
+)
+
+// Conn is one end of a buffered in-memory connection.
+type Conn struct {
+	in       *buffer
+	out      *buffer
+	remoteID id.Identity
+}
+
+// Pipe returns two connected ends reporting a and b as their remote identities.
+func Pipe(a id.Identity, b id.Identity) (*Conn, *Conn) {
+	ab, ba := newBuffer(), newBuffer()
+	return &Conn{in: ba, out: ab, remoteID: a},
+		&Conn{in: ab, out: ba, remoteID: b}
+}
+
+func (c *Conn) RemoteIdentity() id.Identity {
+	return c.remoteID
+}
+
+func (c *Conn) Read(p []byte) (int, error) {
+	return c.in.Read(p)
+}
+
+func (c *Conn) Write(p []byte) (int, error) {
+	return c.out.Write(p)
+}
+
+func (c *Conn) Close() error {
+	c.out.Close()
+	c.in.Close()
+	return nil
+}
+
+type buffer struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	buf    bytes.Buffer
+	closed bool
+}
+
+func newBuffer() *buffer {
+	b := &buffer{}
+	b.cond = sync.NewCond(&b.mu)
+	return b
+}
+
+func (b *buffer) Read(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for b.buf.Len() == 0 && !b.closed {
+		b.cond.Wait()
+	}
+	if b.buf.Len() == 0 {
+		return 0, io.EOF
+	}
+	return b.buf.Read(p)
+}
+
+func (b *buffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return 0, io.ErrClosedPipe
+	}
+	defer b.cond.Broadcast()
+	return b.buf.Write(p)
+}
+
+func (b *buffer) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	b.cond.Broadcast()
+}
@@ -0,0 +1,247 @@
+package jrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/cryptopunkscc/astrald/auth/id"
+	"io"
+	"reflect"
+)
+
+// JsonRpcRequest is a JSON-RPC 2.0 request object. Requests without ID are notifications.
+type JsonRpcRequest struct {
+	JsonRpc string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"`
+}
+
+// JsonRpcResponse is a JSON-RPC 2.0 response object.
+type JsonRpcResponse struct {
+	JsonRpc string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *JsonRpcError   `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// JsonRpcError is a JSON-RPC 2.0 error object.
+type JsonRpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+	err     error
+}
+
+// JsonRpcErrorData is the data of errors returned by handlers. It carries
+// the identifier of the registered sentinel error, and the code and data
+// of Error.
+type JsonRpcErrorData struct {
+	Type string `json:"type,omitempty"`
+	Code int    `json:"code,omitempty"`
+	Data any    `json:"data,omitempty"`
+}
+
+func (e *JsonRpcError) Error() string {
+	return e.Message
+}
+
+// Unwrap returns the registered sentinel error identified by the data of the decoded error.
+func (e *JsonRpcError) Unwrap() error {
+	return e.err
+}
+
+// restoreSentinel looks up the sentinel error by the type in the decoded data.
+func (e *JsonRpcError) restoreSentinel() {
+	if data, ok := e.Data.(map[string]any); ok {
+		typ, _ := data["type"].(string)
+		e.err = sentinelError(typ)
+	}
+}
+
+// Is reports errors with the same code as equal, so the decoded errors match predefined ones.
+func (e *JsonRpcError) Is(target error) bool {
+	t, ok := target.(*JsonRpcError)
+	return ok && t.Code == e.Code
+}
+
+const JsonRpcVersion = "2.0"
+
+var (
+	ErrJsonRpcParse          = &JsonRpcError{Code: -32700, Message: "parse error"}
+	ErrJsonRpcInvalidRequest = &JsonRpcError{Code: -32600, Message: "invalid request"}
+	ErrJsonRpcMethodNotFound = &JsonRpcError{Code: -32601, Message: "method not found"}
+	ErrJsonRpcInvalidParams  = &JsonRpcError{Code: -32602, Message: "invalid params"}
+	ErrJsonRpcUnauthorized   = &JsonRpcError{Code: -32001, Message: ErrUnauthorized.Error()}
+)
+
+// JsonRpcServerError is the code of errors returned by handlers.
+const JsonRpcServerError = -32000
+
+// JsonRpc switches Router.Handle to JSON-RPC 2.0 protocol.
+func (r *Router) JsonRpc() *Router {
+	r.jsonrpc = true
+	return r
+}
+
+// handleJsonRpc serves JSON-RPC 2.0 requests and batches from the connection.
+// Channel results are collected into an array, JSON-RPC has no streaming.
+func (r *Router) handleJsonRpc(ctx context.Context, query any, remoteId id.Identity, conn io.ReadWriteCloser) (err error) {
+	r.Codecs(JsonCodecs).Conn(conn)
+	reader := newHangupReader(conn)
+	defer reader.stop()
+	r.rpc.ByteScannerReader = NewByteScannerReader(reader)
+	for {
+		var message json.RawMessage
+		if err = r.rpc.dec.Decode(&message); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			// the stream cannot be recovered after a syntax error
+			return r.rpc.Encode(newJsonRpcResponse(nil, nil, ErrJsonRpcParse))
+		}
+
+		// calls are cancelled when the client hangs up
+		callCtx, cancelCall := context.WithCancel(ctx)
+		reader.watch(cancelCall)
+		var response any
+		if message = bytes.TrimSpace(message); message[0] == '[' {
+			// batch
+			var batch []json.RawMessage
+			_ = json.Unmarshal(message, &batch)
+			var responses []*JsonRpcResponse
+			for _, m := range batch {
+				if res := r.callJsonRpc(callCtx, query, remoteId, m); res != nil {
+					responses = append(responses, res)
+				}
+			}
+			switch {
+			case len(batch) == 0:
+				response = newJsonRpcResponse(nil, nil, ErrJsonRpcInvalidRequest)
+			case len(responses) > 0:
+				response = responses
+			}
+		} else if res := r.callJsonRpc(callCtx, query, remoteId, message); res != nil {
+			response = res
+		}
+
+		if response != nil {
+			err = r.rpc.Encode(response)
+		}
+		reader.watch(nil)
+		cancelCall()
+		if err != nil {
+			return
+		}
+	}
+}
+
+// callJsonRpc calls the requested method, the response is nil for notifications.
+func (r *Router) callJsonRpc(ctx context.Context, query any, remoteId id.Identity, message json.RawMessage) *JsonRpcResponse {
+	req := JsonRpcRequest{}
+	if err := json.Unmarshal(message, &req); err != nil || req.JsonRpc != JsonRpcVersion || req.Method == "" {
+		return newJsonRpcResponse(req.ID, nil, ErrJsonRpcInvalidRequest)
+	}
+	result, err := r.callJsonRpcMethod(ctx, query, remoteId, req)
+	if req.ID == nil {
+		return nil
+	}
+	return newJsonRpcResponse(req.ID, result, err)
+}
+
+func (r *Router) callJsonRpcMethod(ctx context.Context, query any, remoteId id.Identity, req JsonRpcRequest) (result any, err error) {
+	// setup
+	rr := r.Query(req.Method)
+	if rr.registry.IsEmpty() || rr.args != "" {
+		return nil, ErrJsonRpcMethodNotFound
+	}
+	switch params := bytes.TrimSpace(req.Params); {
+	case len(params) == 0 || string(params) == "null":
+		rr.args = "[]"
+	case params[0] == '[' || params[0] == '{':
+		rr.args = string(params)
+	default:
+		return nil, ErrJsonRpcInvalidParams
+	}
+
+	// authorize
+	if rr.registry.value != r.registry.value && !rr.authorized(ctx, remoteId, query) {
+		return nil, ErrJsonRpcUnauthorized
+	}
+
+	// call
+	ctx, cancel := rr.withTimeout(ctx)
+	defer cancel()
+	results, err := rr.With(ctx, query, remoteId, r.rpc).Call()
+	results, err = deadline(ctx, results, err)
+	switch {
+	case err != nil:
+	case len(results) == 0:
+	case len(results) > 1:
+		result = results
+	case isReader(results):
+		buf := bytes.Buffer{}
+		err = copyReader(&buf, results[0].(io.Reader))
+		result = buf.Bytes()
+	default:
+		values, streamErr, ok := streamOf(results[0])
+		if !ok {
+			result = results[0]
+			break
+		}
+		result = collect(ctx, values)
+		if streamErr != nil && ctx.Err() == nil {
+			err = streamErr()
+		}
+		if _, err = deadline(ctx, nil, err); err != nil {
+			result = nil
+		}
+	}
+	return
+}
+
+// collect reads all values from the channel until it is closed or the context is done.
+func collect(ctx context.Context, c reflect.Value) (values []any) {
+	values = []any{}
+	sel := []reflect.SelectCase{
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())},
+		{Dir: reflect.SelectRecv, Chan: c},
+	}
+	for {
+		i, v, ok := reflect.Select(sel)
+		if i == 0 || !ok {
+			return
+		}
+		values = append(values, v.Interface())
+	}
+}
+
+func newJsonRpcResponse(requestId json.RawMessage, result any, err error) (res *JsonRpcResponse) {
+	res = &JsonRpcResponse{JsonRpc: JsonRpcVersion, ID: requestId}
+	if res.ID == nil {
+		res.ID = json.RawMessage("null")
+	}
+	if err != nil {
+		var e *Error
+		switch {
+		case errors.As(err, &res.Error):
+			return
+		case errors.Is(err, ErrInvalidArgs):
+			res.Error = &JsonRpcError{Code: ErrJsonRpcInvalidParams.Code, Message: err.Error()}
+		case errors.As(err, &e) && e.Code != 0:
+			res.Error = &JsonRpcError{Code: e.Code, Message: err.Error()}
+		default:
+			res.Error = &JsonRpcError{Code: JsonRpcServerError, Message: err.Error()}
+		}
+		if f := newFailure(err); f.Type != "" || f.Code != 0 || f.Data != nil {
+			res.Error.Data = &JsonRpcErrorData{Type: f.Type, Code: f.Code, Data: f.Data}
+		}
+		return
+	}
+	if res.Result, err = json.Marshal(result); err != nil {
+		res.Result = nil
+		res.Error = &JsonRpcError{Code: JsonRpcServerError, Message: err.Error()}
+	}
+	return
+}
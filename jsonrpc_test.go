@@ -0,0 +1,141 @@
+package jrpc
+
+import (
+	"bufio"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestRouter_JsonRpc(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	router := NewRouter("test").JsonRpc()
+	router.Func("sum", func(a, b int) int { return a + b })
+	router.Func("echo", func(arg testRouterStruct) testRouterStruct { return arg })
+	router.Func("count", func(n int) <-chan int {
+		c := make(chan int)
+		go func() {
+			defer close(c)
+			for i := 0; i < n; i++ {
+				c <- i
+			}
+		}()
+		return c
+	})
+	router.Func("secret", func() string { return "secret" })
+	router.Func("secret!", func() bool { return false })
+	router.Func("fail", func() error { return NewError(409, "conflict").WithData("x") })
+	router.Func("deny", func() error { return ErrUnauthorized })
+	stopped := make(chan string, 1)
+	router.Func("wait", func(ctx context.Context) int {
+		<-ctx.Done()
+		stopped <- "wait"
+		return 0
+	})
+	router.Func("ticks", func(ctx context.Context) <-chan int {
+		c := make(chan int)
+		go func() {
+			defer close(c)
+			<-ctx.Done()
+			stopped <- "ticks"
+		}()
+		return c
+	})
+
+	t.Run("raw", func(t *testing.T) {
+		conn := serve(ctx, t, router)
+		reader := bufio.NewReader(conn)
+		tests := []struct {
+			name     string
+			request  string
+			expected string
+		}{
+			{"positional params", `{"jsonrpc":"2.0","method":"sum","params":[1,2],"id":1}`,
+				`{"jsonrpc":"2.0","result":3,"id":1}`},
+			{"named params", `{"jsonrpc":"2.0","method":"echo","params":{"i":1,"s":"a"},"id":"a"}`,
+				`{"jsonrpc":"2.0","result":{"i":1,"s":"a"},"id":"a"}`},
+			{"stream", `{"jsonrpc":"2.0","method":"count","params":[3],"id":2}`,
+				`{"jsonrpc":"2.0","result":[0,1,2],"id":2}`},
+			{"method not found", `{"jsonrpc":"2.0","method":"unknown","id":3}`,
+				`{"jsonrpc":"2.0","error":{"code":-32601,"message":"method not found"},"id":3}`},
+			{"unauthorized", `{"jsonrpc":"2.0","method":"secret","id":4}`,
+				`{"jsonrpc":"2.0","error":{"code":-32001,"message":"unauthorized"},"id":4}`},
+			{"invalid request", `{"method":"sum","id":5}`,
+				`{"jsonrpc":"2.0","error":{"code":-32600,"message":"invalid request"},"id":5}`},
+			{"notification and request", `{"jsonrpc":"2.0","method":"sum","params":[1,1]}{"jsonrpc":"2.0","method":"sum","params":[2,2],"id":6}`,
+				`{"jsonrpc":"2.0","result":4,"id":6}`},
+			{"batch", `[{"jsonrpc":"2.0","method":"sum","params":[1,2],"id":7},{"jsonrpc":"2.0","method":"sum","params":[1,2]},1]`,
+				`[{"jsonrpc":"2.0","result":3,"id":7},{"jsonrpc":"2.0","error":{"code":-32600,"message":"invalid request"},"id":null}]`},
+			{"empty batch", `[]`,
+				`{"jsonrpc":"2.0","error":{"code":-32600,"message":"invalid request"},"id":null}`},
+			{"invalid params", `{"jsonrpc":"2.0","method":"sum","params":["a",1],"id":8}`,
+				`{"jsonrpc":"2.0","error":{"code":-32602,"message":"invalid arguments: json: cannot unmarshal string into .0 of type int","data":{"type":"invalid_args","code":400}},"id":8}`},
+			{"error data", `{"jsonrpc":"2.0","method":"fail","id":9}`,
+				`{"jsonrpc":"2.0","error":{"code":409,"message":"conflict","data":{"code":409,"data":"x"}},"id":9}`},
+			{"sentinel", `{"jsonrpc":"2.0","method":"deny","id":10}`,
+				`{"jsonrpc":"2.0","error":{"code":-32000,"message":"unauthorized","data":{"type":"unauthorized"}},"id":10}`},
+			{"parse error", `{"jsonrpc":]`,
+				`{"jsonrpc":"2.0","error":{"code":-32700,"message":"parse error"},"id":null}`},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				if _, err := conn.Write([]byte(tt.request + "\n")); err != nil {
+					t.Fatal(err)
+				}
+				line, err := reader.ReadString('\n')
+				assert.NoError(t, err)
+				assert.JSONEq(t, tt.expected, line)
+			})
+		}
+	})
+
+	t.Run("flow", func(t *testing.T) {
+		conn := NewJsonRpcFlow(serve(ctx, t, router))
+
+		r, err := Query[int](conn, "sum", 1, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, r)
+
+		_, err = Query[int](conn, "unknown")
+		assert.ErrorIs(t, err, ErrJsonRpcMethodNotFound)
+
+		_, err = Query[int](conn, "sum", "a", 1)
+		assert.ErrorIs(t, err, ErrJsonRpcInvalidParams)
+		assert.ErrorIs(t, err, ErrInvalidArgs)
+
+		err = Command(conn, "deny")
+		assert.ErrorIs(t, err, ErrUnauthorized)
+
+		assert.NoError(t, conn.Notify("sum", []int{1, 2}))
+
+		req1, _ := conn.Request("sum", []int{2, 2})
+		req2, _ := NewJsonRpcNotification("sum", []int{3, 3})
+		req3, _ := conn.Request("count", []int{2})
+		res, err := conn.Batch(req1, req2, req3)
+		assert.NoError(t, err)
+		if assert.Len(t, res, 2) {
+			assert.JSONEq(t, `4`, string(res[0].Result))
+			assert.JSONEq(t, `[0,1]`, string(res[1].Result))
+		}
+	})
+	t.Run("disconnect", func(t *testing.T) {
+		for _, method := range []string{"wait", "ticks"} {
+			t.Run(method, func(t *testing.T) {
+				conn := serve(ctx, t, router)
+				if _, err := conn.Write([]byte(`{"jsonrpc":"2.0","method":"` + method + `","id":1}` + "\n")); err != nil {
+					t.Fatal(err)
+				}
+				_ = conn.Close()
+				select {
+				case s := <-stopped:
+					assert.Equal(t, method, s)
+				case <-time.After(time.Second):
+					t.Fatal("call not cancelled")
+				}
+			})
+		}
+	})
+}
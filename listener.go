@@ -0,0 +1,21 @@
+package jrpc
+
+import (
+	"context"
+	"errors"
+	"github.com/cryptopunkscc/astrald/auth/id"
+	"io"
+)
+
+type Listener interface {
+	Listen(ctx context.Context, route string) (<-chan IncomingQuery, error)
+}
+
+type IncomingQuery interface {
+	Query() string
+	RemoteIdentity() id.Identity
+	Accept() (io.ReadWriteCloser, error)
+	Reject() error
+}
+
+var ErrNoListener = errors.New("no listener")
@@ -0,0 +1,109 @@
+package jrpc
+
+import (
+	"context"
+	"github.com/cryptopunkscc/astrald/auth/id"
+	"github.com/cryptopunkscc/astrald/net"
+	"github.com/cryptopunkscc/astrald/node"
+	"io"
+	"sync"
+)
+
+type moduleListener struct {
+	node node.Node
+}
+
+func NewModuleListener(node node.Node) Listener {
+	return moduleListener{node: node}
+}
+
+func (l moduleListener) Listen(ctx context.Context, route string) (<-chan IncomingQuery, error) {
+	r := &moduleRoute{ctx: ctx, queries: make(chan IncomingQuery)}
+	if err := l.node.LocalRouter().AddRoute(route, r); err != nil {
+		return nil, err
+	}
+	go func() {
+		<-ctx.Done()
+		_ = l.node.LocalRouter().RemoveRoute(route)
+		r.mu.Lock()
+		close(r.queries)
+		r.mu.Unlock()
+	}()
+	return r.queries, nil
+}
+
+type moduleRoute struct {
+	ctx     context.Context
+	mu      sync.RWMutex
+	queries chan IncomingQuery
+}
+
+func (r *moduleRoute) RouteQuery(ctx context.Context, query net.Query, caller net.SecureWriteCloser, _ net.Hints) (net.SecureWriteCloser, error) {
+	q := &moduleQuery{
+		query:    query,
+		accepted: make(chan bool, 1),
+		conn:     make(chan io.ReadWriteCloser, 1),
+	}
+	if err := r.dispatch(ctx, q); err != nil {
+		return nil, err
+	}
+	if !<-q.accepted {
+		return nil, net.ErrRejected
+	}
+	w, err := net.Accept(query, caller, func(conn net.SecureConn) {
+		q.conn <- conn
+	})
+	if err != nil {
+		q.err = err
+		close(q.conn)
+	}
+	return w, err
+}
+
+// dispatch passes the query to the listener, unless the route was removed
+// and the queries channel closed.
+func (r *moduleRoute) dispatch(ctx context.Context, q *moduleQuery) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	select {
+	case <-r.ctx.Done():
+		return net.ErrRejected
+	default:
+	}
+	select {
+	case r.queries <- q:
+		return nil
+	case <-r.ctx.Done():
+		return net.ErrRejected
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type moduleQuery struct {
+	query    net.Query
+	accepted chan bool
+	conn     chan io.ReadWriteCloser
+	err      error
+}
+
+func (q *moduleQuery) Query() string {
+	return q.query.Query()
+}
+
+func (q *moduleQuery) RemoteIdentity() id.Identity {
+	return q.query.Caller()
+}
+
+func (q *moduleQuery) Accept() (io.ReadWriteCloser, error) {
+	q.accepted <- true
+	if conn, ok := <-q.conn; ok {
+		return conn, nil
+	}
+	return nil, q.err
+}
+
+func (q *moduleQuery) Reject() error {
+	q.accepted <- false
+	return nil
+}
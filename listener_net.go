@@ -0,0 +1,131 @@
+package jrpc
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/cryptopunkscc/astrald/auth/id"
+	"io"
+	"net"
+	"strings"
+	"sync"
+)
+
+// NetListener serves queries from a net.Listener, e.g. a unix domain socket
+// or TCP. The first line of each connection carries the query.
+type NetListener struct {
+	QueryMux
+	listener net.Listener
+}
+
+var ErrRejected = RegisterError("rejected", errors.New("rejected"))
+var ErrQueryTooLong = errors.New("query too long")
+
+const maxQueryLen = 64 * 1024
+
+func ListenNet(network, address string) (l *NetListener, err error) {
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		return
+	}
+	return NewNetListener(listener), nil
+}
+
+func NewNetListener(listener net.Listener) *NetListener {
+	l := &NetListener{listener: listener}
+	go l.accept()
+	return l
+}
+
+func (l *NetListener) Addr() net.Addr {
+	return l.listener.Addr()
+}
+
+func (l *NetListener) Close() error {
+	return l.listener.Close()
+}
+
+func (l *NetListener) accept() {
+	for {
+		conn, err := l.listener.Accept()
+		if err != nil {
+			return
+		}
+		go l.dispatch(conn)
+	}
+}
+
+func (l *NetListener) dispatch(conn net.Conn) {
+	query, err := readQuery(conn)
+	if err != nil {
+		_ = conn.Close()
+		return
+	}
+	q := &netQuery{query: query, conn: conn}
+	if !l.Dispatch(q) {
+		_ = q.Reject()
+	}
+}
+
+func readQuery(r io.Reader) (query string, err error) {
+	var b [1]byte
+	var line []byte
+	for {
+		if _, err = r.Read(b[:]); err != nil {
+			return
+		}
+		if b[0] == '\n' {
+			break
+		}
+		if line = append(line, b[0]); len(line) > maxQueryLen {
+			return "", ErrQueryTooLong
+		}
+	}
+	return strings.TrimSuffix(string(line), "\r"), nil
+}
+
+type netQuery struct {
+	query string
+	conn  net.Conn
+	once  sync.Once
+}
+
+func (q *netQuery) Query() string {
+	return q.query
+}
+
+func (q *netQuery) RemoteIdentity() id.Identity {
+	return id.Anyone
+}
+
+func (q *netQuery) RemoteAddr() net.Addr {
+	return q.conn.RemoteAddr()
+}
+
+func (q *netQuery) Accept() (conn io.ReadWriteCloser, err error) {
+	err = ErrRejected
+	q.once.Do(func() {
+		conn, err = q.conn, nil
+	})
+	return
+}
+
+func (q *netQuery) Reject() (err error) {
+	q.once.Do(func() {
+		_ = json.NewEncoder(q.conn).Encode(newFailure(ErrRejected))
+		err = q.conn.Close()
+	})
+	return
+}
+
+// DialFlow connects to a NetListener and sends the query as the first line.
+func DialFlow(network, address, query string) (c Conn, err error) {
+	conn, err := net.Dial(network, address)
+	if err != nil {
+		return
+	}
+	if _, err = conn.Write([]byte(query + "\n")); err != nil {
+		_ = conn.Close()
+		return
+	}
+	return NewFlow(conn), nil
+}
@@ -0,0 +1,89 @@
+package jrpc
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"testing"
+)
+
+func TestNetListener(t *testing.T) {
+	networks := []struct {
+		network string
+		address string
+	}{
+		{"unix", filepath.Join(t.TempDir(), "jrpc.sock")},
+		{"tcp", "127.0.0.1:0"},
+	}
+	for _, n := range networks {
+		t.Run(n.network, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			listener, err := ListenNet(n.network, n.address)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() {
+				cancel()
+				_ = listener.Close()
+			})
+			address := listener.Addr().String()
+
+			router := NewRouter("test").Listener(listener).Routes("*")
+			router.Func("sum", func(a, b int) int { return a + b })
+			router.Func("secret", func() string { return "secret" })
+			router.Func("secret!", func() bool { return false })
+			if err = router.Run(ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			t.Run("query args", func(t *testing.T) {
+				conn, err := DialFlow(n.network, address, "test.sum?[1,2]")
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer conn.Close()
+				r, err := Decode[int](conn)
+				assert.NoError(t, err)
+				assert.Equal(t, 3, r)
+			})
+
+			t.Run("flow", func(t *testing.T) {
+				conn, err := DialFlow(n.network, address, "test")
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer conn.Close()
+				r, err := Query[int](conn, "sum", 2, 2)
+				assert.NoError(t, err)
+				assert.Equal(t, 4, r)
+
+				api, err := Query[[]string](conn, "api")
+				assert.NoError(t, err)
+				assert.ElementsMatch(t, []string{"sum", "secret"}, api)
+
+				_, err = Query[string](conn, "secret")
+				assert.EqualError(t, err, ErrUnauthorized.Error())
+			})
+
+			t.Run("unauthorized", func(t *testing.T) {
+				conn, err := DialFlow(n.network, address, "test.secret")
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer conn.Close()
+				_, err = Decode[string](conn)
+				assert.EqualError(t, err, ErrRejected.Error())
+			})
+
+			t.Run("not registered", func(t *testing.T) {
+				conn, err := DialFlow(n.network, address, "other")
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer conn.Close()
+				_, err = Decode[string](conn)
+				assert.EqualError(t, err, ErrRejected.Error())
+			})
+		})
+	}
+}
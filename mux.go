@@ -0,0 +1,85 @@
+package jrpc
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+)
+
+type QueryMux struct {
+	mu     sync.Mutex
+	routes map[string]*muxRoute
+}
+
+type muxRoute struct {
+	ctx     context.Context
+	mu      sync.RWMutex
+	queries chan IncomingQuery
+}
+
+var ErrRouteTaken = errors.New("route already registered")
+
+func (m *QueryMux) Listen(ctx context.Context, route string) (<-chan IncomingQuery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.routes == nil {
+		m.routes = make(map[string]*muxRoute)
+	}
+	if r, ok := m.routes[route]; ok && r.ctx.Err() == nil {
+		return nil, ErrRouteTaken
+	}
+	r := &muxRoute{ctx: ctx, queries: make(chan IncomingQuery)}
+	m.routes[route] = r
+	go func() {
+		<-ctx.Done()
+		m.mu.Lock()
+		if m.routes[route] == r {
+			delete(m.routes, route)
+		}
+		m.mu.Unlock()
+		r.mu.Lock()
+		close(r.queries)
+		r.mu.Unlock()
+	}()
+	return r.queries, nil
+}
+
+// Dispatch passes the query to the listener of the best matching route.
+// Returns false if no route matches the query.
+func (m *QueryMux) Dispatch(query IncomingQuery) bool {
+	r := m.match(query.Query())
+	if r == nil {
+		return false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	select {
+	case <-r.ctx.Done():
+		return false
+	default:
+	}
+	select {
+	case r.queries <- query:
+		return true
+	case <-r.ctx.Done():
+		return false
+	}
+}
+
+func (m *QueryMux) match(query string) (route *muxRoute) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	name, _, _ := strings.Cut(query, "?")
+	if r, ok := m.routes[name]; ok {
+		return r
+	}
+	l := -1
+	for s, r := range m.routes {
+		prefix, ok := strings.CutSuffix(s, "*")
+		if ok && strings.HasPrefix(name, prefix) && len(prefix) > l {
+			route, l = r, len(prefix)
+		}
+	}
+	return
+}
@@ -0,0 +1,192 @@
+package jrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// A handler returning io.Reader responds with the byte stream frame followed
+// by chunks of raw bytes. Each chunk is preceded by its decimal length in a
+// separate line. The stream is finished with the zero length, or with -1
+// followed by the error, so the connection can be used for the next request.
+
+// ByteStream is the frame sent before the raw bytes of an io.Reader result.
+var ByteStream = struct {
+	Stream string `json:"stream"`
+}{Stream: "bytes"}
+
+var ErrNotByteStream = errors.New("response is not a byte stream")
+var ErrTaggedReader = errors.New("byte streams are not supported in tagged calls")
+
+const byteStreamChunk = 32 * 1024
+
+// rawChunk is written to the connection as it is by the response encoders,
+// so the chunks don't interleave with the responses of concurrent calls.
+type rawChunk []byte
+
+// encodeResponse writes the raw chunk, or encodes the value.
+func encodeResponse(rpc *Flow, value any) (err error) {
+	if b, ok := value.(rawChunk); ok {
+		_, err = rpc.Write(b)
+		return
+	}
+	return rpc.Encode(value)
+}
+
+// respondReader sends the bytes of the reader in chunks and closes it.
+func (r *Router) respondReader(ctx context.Context, encode func(any) error, reader io.Reader) bool {
+	if c, ok := reader.(io.Closer); ok {
+		defer c.Close()
+	}
+	if encode(ByteStream) != nil {
+		return false
+	}
+	buf := make([]byte, byteStreamChunk+32)
+	for ctx.Err() == nil {
+		head := 0
+		n, err := reader.Read(buf[32:])
+		if n > 0 {
+			size := strconv.Itoa(n) + "\n"
+			head = 32 - len(size)
+			copy(buf[head:], size)
+			if encode(rawChunk(buf[head:32+n])) != nil {
+				return false
+			}
+		}
+		switch {
+		case errors.Is(err, io.EOF):
+			return encode(rawChunk("0\n")) == nil
+		case err != nil:
+			if encode(rawChunk("-1\n")) != nil {
+				return false
+			}
+			return encode(err) == nil
+		}
+	}
+	return false
+}
+
+// copyReader copies the bytes of the reader and closes it.
+func copyReader(w io.Writer, reader io.Reader) (err error) {
+	if c, ok := reader.(io.Closer); ok {
+		defer c.Close()
+	}
+	_, err = io.Copy(w, reader)
+	return
+}
+
+func isReader(result []any) bool {
+	if len(result) != 1 {
+		return false
+	}
+	_, ok := result[0].(io.Reader)
+	return ok
+}
+
+// QueryReader calls the method of a handler returning io.Reader and returns
+// the reader of the streamed bytes. Closing the reader before the end of
+// stream closes the connection.
+func QueryReader(conn Conn, method string, args ...any) (reader io.ReadCloser, err error) {
+	switch conn.(type) {
+	case *MuxFlow, *muxCall, *pooledRequest:
+		return nil, ErrTaggedReader
+	}
+	conn = conn.Copy()
+	if err = Call(conn, method, args...); err != nil {
+		conn.Flush()
+		return
+	}
+	d, ok := conn.(interface{ valueDecoder() *Serializer })
+	if !ok {
+		conn.Flush()
+		return nil, fmt.Errorf("%w: unsupported connection %T", ErrNotByteStream, conn)
+	}
+	r := &byteStreamReader{conn: conn, in: d.valueDecoder()}
+	h := remoteValue{value: &frame{}}
+	switch err = r.in.Decode(&h); {
+	case h.remote:
+	case err == nil && h.value.(*frame).Stream == ByteStream.Stream:
+	case err == nil || !errors.Is(err, io.EOF):
+		err = ErrNotByteStream
+	}
+	if err != nil {
+		conn.Flush()
+		return nil, err
+	}
+	return r, nil
+}
+
+type byteStreamReader struct {
+	conn      Conn
+	in        *Serializer
+	remaining int
+	err       error
+}
+
+func (r *byteStreamReader) Read(p []byte) (n int, err error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	if r.remaining == 0 {
+		if err = r.next(); err != nil {
+			return
+		}
+	}
+	if len(p) > r.remaining {
+		p = p[:r.remaining]
+	}
+	n, err = r.conn.Read(p)
+	r.remaining -= n
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		r.err = err
+	}
+	return
+}
+
+// next reads the length of the next chunk, skipping the new lines left after values.
+func (r *byteStreamReader) next() (err error) {
+	line := ""
+	for line == "" && err == nil {
+		line, err = readCommand(r.conn)
+	}
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+	size := 0
+	if err == nil {
+		size, err = strconv.Atoi(line)
+	}
+	switch {
+	case err != nil:
+	case size == 0:
+		err = io.EOF
+		r.conn.Flush()
+	case size < 0:
+		var v any
+		if err = r.in.Decode(&v); err == nil {
+			err = io.ErrUnexpectedEOF
+		}
+		r.conn.Flush()
+	default:
+		r.remaining = size
+	}
+	r.err = err
+	return
+}
+
+// Close closes the connection if the stream was not read to the end.
+func (r *byteStreamReader) Close() error {
+	if r.err == nil {
+		r.err = io.ErrClosedPipe
+		err := r.conn.Close()
+		r.conn.Flush()
+		return err
+	}
+	return nil
+}
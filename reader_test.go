@@ -0,0 +1,130 @@
+package jrpc
+
+import (
+	"context"
+	"github.com/cryptopunkscc/astrald/auth/id"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"testing/iotest"
+)
+
+func TestQueryReader(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	data := strings.Repeat("0123456789", 10000)
+	closed := make(chan struct{}, 1)
+	router := NewRouter("test")
+	router.Func("read", func(n int) io.ReadCloser {
+		return readCloser{strings.NewReader(data[:n]), closed}
+	})
+	router.Func("broken", func() io.Reader {
+		return io.MultiReader(strings.NewReader("abc"), iotest.ErrReader(ErrUnauthorized))
+	})
+	router.Func("fail", func() (io.Reader, error) { return nil, ErrMalformedRequest })
+	router.Func("sum", func(a, b int) int { return a + b })
+
+	conn := NewFlow(serve(ctx, t, router))
+
+	t.Run("read", func(t *testing.T) {
+		for _, n := range []int{0, 10, len(data)} {
+			r, err := QueryReader(conn, "read", n)
+			assert.NoError(t, err)
+			b, err := io.ReadAll(r)
+			assert.NoError(t, err)
+			assert.Equal(t, data[:n], string(b))
+			assert.NoError(t, r.Close())
+			<-closed
+		}
+	})
+
+	t.Run("broken", func(t *testing.T) {
+		r, err := QueryReader(conn, "broken")
+		assert.NoError(t, err)
+		b, err := io.ReadAll(r)
+		assert.Equal(t, "abc", string(b))
+		assert.ErrorIs(t, err, ErrUnauthorized)
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		_, err := QueryReader(conn, "fail")
+		assert.ErrorIs(t, err, ErrMalformedRequest)
+	})
+
+	t.Run("connection is reusable", func(t *testing.T) {
+		r, err := Query[int](conn, "sum", 1, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, r)
+	})
+
+	t.Run("not a byte stream", func(t *testing.T) {
+		_, err := QueryReader(conn, "sum", 1, 2)
+		assert.ErrorIs(t, err, ErrNotByteStream)
+	})
+
+	t.Run("tagged", func(t *testing.T) {
+		_, err := QueryReader(NewMuxFlow(serve(ctx, t, router)), "read", 1)
+		assert.ErrorIs(t, err, ErrTaggedReader)
+	})
+
+	t.Run("chunks and tagged responses", func(t *testing.T) {
+		r := NewRouter("test")
+		r.Func("read", func() io.Reader { return iotest.OneByteReader(strings.NewReader(data)) })
+		r.Func("ticks", func(ctx context.Context) <-chan int {
+			c := make(chan int)
+			go func() {
+				defer close(c)
+				for i := 0; ; i++ {
+					select {
+					case c <- i:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}()
+			return c
+		})
+		server, client := net.Pipe()
+		w := &writeChecker{Conn: server}
+		go func() {
+			defer server.Close()
+			_ = r.Handle(ctx, "test", id.Anyone, w)
+		}()
+		defer client.Close()
+		if _, err := io.WriteString(client, "#1:ticks\nread\n"); err != nil {
+			t.Fatal(err)
+		}
+		_, _ = io.CopyN(io.Discard, client, int64(2*len(data)))
+		assert.False(t, w.overlapped.Load(), "writes overlapped")
+	})
+}
+
+// writeChecker reports writes made while another write is in progress.
+type writeChecker struct {
+	net.Conn
+	busy       atomic.Bool
+	overlapped atomic.Bool
+}
+
+func (c *writeChecker) Write(p []byte) (int, error) {
+	if !c.busy.CompareAndSwap(false, true) {
+		c.overlapped.Store(true)
+	} else {
+		defer c.busy.Store(false)
+	}
+	return c.Conn.Write(p)
+}
+
+type readCloser struct {
+	io.Reader
+	closed chan struct{}
+}
+
+func (r readCloser) Close() error {
+	r.closed <- struct{}{}
+	return nil
+}
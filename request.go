@@ -2,13 +2,16 @@ package jrpc
 
 import (
 	"github.com/cryptopunkscc/astrald/auth/id"
-	"github.com/cryptopunkscc/astrald/lib/astral"
 	"io"
+	"sync"
 )
 
 type Request struct {
 	*Serializer
 	service string
+	dialer  Dialer
+	mu      sync.Mutex
+	closed  bool
 }
 
 func NewRequest(
@@ -20,17 +23,35 @@ func NewRequest(
 			remoteID: identity,
 		},
 		service: service,
+		dialer:  DefaultApphost,
 	}
 }
 
 func (r *Request) Copy() Conn {
-	rr := NewRequest(r.remoteID, r.service)
+	rr := &Request{
+		Serializer: &Serializer{remoteID: r.remoteID, codecs: r.codecs},
+		service:    r.service,
+		dialer:     r.dialer,
+	}
 	if r.logger != nil {
 		rr.Logger(r.logger.Logger)
 	}
 	return rr
 }
 
+// Close closes the query stream. It can be called concurrently with Call, e.g.
+// when the context of the call is done, the stream queried after is closed
+// right away.
+func (r *Request) Close() (err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	if r.WriteCloser != nil {
+		err = r.WriteCloser.Close()
+	}
+	return
+}
+
 func (r *Request) Flush() {
 	if r.WriteCloser != nil {
 		_ = r.WriteCloser.Close()
@@ -71,11 +92,17 @@ func (r *Request) Call(method string, value any) (err error) {
 
 	// query stream
 	var conn io.ReadWriteCloser
-	if conn, err = astral.Query(r.RemoteIdentity(), query); err != nil {
+	if conn, err = r.dialer.Query(r.RemoteIdentity(), query); err != nil {
 		return
 	}
 
 	// setup
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		_ = conn.Close()
+		return io.ErrClosedPipe
+	}
 	r.setConn(conn)
 	return
 }
@@ -0,0 +1,256 @@
+package jrpc
+
+import (
+	"errors"
+	"fmt"
+	"github.com/cryptopunkscc/astrald/auth/id"
+	"io"
+	"log"
+	"sync"
+	"sync/atomic"
+)
+
+// Pool keeps live flows per identity and service, so sequential calls made
+// with pooled requests reuse connections instead of querying a new one for
+// each call. Pooled calls are sent with request IDs, so the responses left
+// by a previous call are skipped. A flow is returned to the pool after the
+// call, which is cancelled unless its end was received. Failed flows are
+// evicted, idle flows closed by the service are replaced on the next call.
+type Pool struct {
+	dialer  Dialer
+	max     int
+	mu      sync.Mutex
+	cond    *sync.Cond
+	entries map[poolKey]*poolEntry
+	stats   PoolStats
+	closed  bool
+	lastId  atomic.Uint64
+}
+
+type PoolStats struct {
+	Open    int // live flows
+	Idle    int // flows waiting for reuse
+	Dialed  int // flows queried in total
+	Reused  int // calls made over an idle flow
+	Evicted int // flows closed after failure
+}
+
+type poolKey struct {
+	identity string
+	service  string
+}
+
+type poolEntry struct {
+	idle []*Flow
+	open int
+}
+
+var ErrPoolClosed = errors.New("pool closed")
+
+// NewPool creates a pool limited to max live flows per identity and service.
+func NewPool(max int) *Pool {
+	if max < 1 {
+		max = 1
+	}
+	p := &Pool{dialer: DefaultApphost, max: max, entries: map[poolKey]*poolEntry{}}
+	p.cond = sync.NewCond(&p.mu)
+	return p
+}
+
+func (p *Pool) Dialer(dialer Dialer) *Pool {
+	p.dialer = dialer
+	return p
+}
+
+// Request returns a Conn making calls over pooled flows to the service.
+func (p *Pool) Request(identity id.Identity, service string) Conn {
+	return &pooledRequest{pool: p, identity: identity, service: service}
+}
+
+func (p *Pool) Stats() (s PoolStats) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s = p.stats
+	for _, e := range p.entries {
+		s.Open += e.open
+		s.Idle += len(e.idle)
+	}
+	return
+}
+
+// Close closes idle flows, the flows in use are closed when released.
+func (p *Pool) Close() (err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	for _, e := range p.entries {
+		for _, f := range e.idle {
+			_ = f.Close()
+		}
+		e.open -= len(e.idle)
+		e.idle = nil
+	}
+	p.cond.Broadcast()
+	return
+}
+
+func (p *Pool) acquire(identity id.Identity, service string) (flow *Flow, reused bool, err error) {
+	key := poolKey{identity.String(), service}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for {
+		if p.closed {
+			return nil, false, ErrPoolClosed
+		}
+		e := p.entries[key]
+		if e == nil {
+			e = &poolEntry{}
+			p.entries[key] = e
+		}
+
+		// reuse
+		if n := len(e.idle); n > 0 {
+			flow = e.idle[n-1]
+			e.idle = e.idle[:n-1]
+			p.stats.Reused++
+			return flow, true, nil
+		}
+
+		// dial
+		if e.open < p.max {
+			e.open++
+			p.mu.Unlock()
+			conn, err := p.dialer.Query(identity, service)
+			p.mu.Lock()
+			if err != nil {
+				e.open--
+				p.cond.Broadcast()
+				return nil, false, err
+			}
+			p.stats.Dialed++
+			return NewFlow(conn), false, nil
+		}
+
+		// wait for release
+		p.cond.Wait()
+	}
+}
+
+func (p *Pool) release(identity id.Identity, service string, flow *Flow, reuse bool) {
+	key := poolKey{identity.String(), service}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	defer p.cond.Broadcast()
+	e := p.entries[key]
+	if reuse && !p.closed {
+		e.idle = append(e.idle, flow)
+		return
+	}
+	_ = flow.Close()
+	e.open--
+	if !reuse {
+		p.stats.Evicted++
+	}
+}
+
+// pooledRequest is a Conn acquiring a flow from the pool for each call.
+type pooledRequest struct {
+	*Flow
+	pool     *Pool
+	identity id.Identity
+	service  string
+	logger   *log.Logger
+	codecs   Codecs
+	id       uint64
+	done     bool
+	broken   bool
+}
+
+func (r *pooledRequest) Copy() Conn {
+	return &pooledRequest{
+		pool:     r.pool,
+		identity: r.identity,
+		service:  r.service,
+		logger:   r.logger,
+		codecs:   r.codecs,
+	}
+}
+
+func (r *pooledRequest) Logger(logger *log.Logger) {
+	r.logger = logger
+}
+
+func (r *pooledRequest) Codecs(codecs Codecs) {
+	r.codecs = codecs
+}
+
+func (r *pooledRequest) Call(method string, value any) (err error) {
+	r.Flush()
+	for {
+		var reused bool
+		if r.Flow, reused, err = r.pool.acquire(r.identity, r.service); err != nil {
+			return
+		}
+		if r.logger != nil {
+			r.Flow.Logger(r.logger)
+		}
+		if r.codecs != nil {
+			r.Flow.Codecs(r.codecs)
+		}
+		r.id = r.pool.lastId.Add(1)
+		if err = r.Flow.Call(fmt.Sprintf("#%d:%s", r.id, method), value); err == nil {
+			return
+		}
+		// the idle flow was closed by the service, try the next one
+		_ = r.Close()
+		if !reused {
+			return
+		}
+	}
+}
+
+// Decode reads the next response of the call, io.EOF is returned at the end of stream.
+func (r *pooledRequest) Decode(value any) (err error) {
+	if r.Flow == nil {
+		return ErrPoolClosed
+	}
+	for {
+		b := raw{}
+		if err = r.dec.Decode(&b); err != nil {
+			r.broken = true
+			return
+		}
+		msg := taggedMessage{}
+		if err = r.unmarshal(b.bytes, &msg); err != nil || msg.ID != r.id {
+			continue // drop the response of a previous call
+		}
+		if msg.Done {
+			r.done = true
+			return io.EOF
+		}
+		return r.decodeRaw(msg.Value.bytes, value)
+	}
+}
+
+// Flush cancels the call unless its end was received and returns the flow
+// to the pool. The responses sent before the cancellation are dropped by
+// the next call.
+func (r *pooledRequest) Flush() {
+	if r.Flow == nil {
+		return
+	}
+	if !r.broken && !r.done {
+		if err := r.Flow.Call(fmt.Sprintf("#%d:", r.id), nil); err != nil {
+			r.broken = true
+		}
+	}
+	r.pool.release(r.identity, r.service, r.Flow, !r.broken)
+	r.Flow, r.done, r.broken = nil, false, false
+}
+
+// Close evicts the flow from the pool.
+func (r *pooledRequest) Close() error {
+	r.broken = true
+	r.Flush()
+	return nil
+}
@@ -0,0 +1,146 @@
+package jrpc
+
+import (
+	"context"
+	"errors"
+	"github.com/cryptopunkscc/astrald/auth/id"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net"
+	"sync"
+	"testing"
+)
+
+type testDialer func(identity id.Identity, query string) (io.ReadWriteCloser, error)
+
+func (d testDialer) Query(identity id.Identity, query string) (io.ReadWriteCloser, error) {
+	return d(identity, query)
+}
+
+func TestPool(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	router := NewRouter("test")
+	router.Func("sum", func(a, b int) int { return a + b })
+	router.Func("fail", func() error { return errors.New("fail") })
+	router.Func("count", func(n int) <-chan int {
+		c := make(chan int)
+		go func() {
+			defer close(c)
+			for i := 0; i < n; i++ {
+				c <- i
+			}
+		}()
+		return c
+	})
+
+	mu := sync.Mutex{}
+	var servers []net.Conn
+	pool := NewPool(2).Dialer(testDialer(func(identity id.Identity, query string) (io.ReadWriteCloser, error) {
+		server, client := net.Pipe()
+		mu.Lock()
+		servers = append(servers, server)
+		mu.Unlock()
+		go func() {
+			defer server.Close()
+			_ = router.Handle(ctx, query, identity, server)
+		}()
+		return client, nil
+	}))
+	t.Cleanup(func() { _ = pool.Close() })
+	conn := pool.Request(id.Anyone, "test")
+
+	t.Run("sequential calls reuse flow", func(t *testing.T) {
+		for i := 0; i < 5; i++ {
+			r, err := Query[int](conn, "sum", i, 1)
+			assert.NoError(t, err)
+			assert.Equal(t, i+1, r)
+		}
+		s := pool.Stats()
+		assert.Equal(t, 1, s.Dialed)
+		assert.Equal(t, 4, s.Reused)
+		assert.Equal(t, 1, s.Idle)
+	})
+
+	t.Run("failure response keeps flow", func(t *testing.T) {
+		err := Command(conn, "fail")
+		assert.EqualError(t, err, "fail")
+		r, err := Query[int](conn, "sum", 1, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, r)
+		assert.Equal(t, 1, pool.Stats().Dialed)
+	})
+
+	t.Run("finished stream keeps flow", func(t *testing.T) {
+		c := conn.Copy()
+		assert.NoError(t, Call(c, "count", 3))
+		var values []int
+		for {
+			v, err := Decode[int](c)
+			if err != nil {
+				assert.ErrorIs(t, err, io.EOF)
+				break
+			}
+			values = append(values, v)
+		}
+		c.Flush()
+		assert.Equal(t, []int{0, 1, 2}, values)
+		s := pool.Stats()
+		assert.Equal(t, 0, s.Evicted)
+		assert.Equal(t, 1, s.Idle)
+	})
+
+	t.Run("unfinished stream is cancelled", func(t *testing.T) {
+		r, err := Query[int](conn, "count", 3)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, r)
+		for i := 0; i < 3; i++ {
+			r, err = Query[int](conn, "sum", i, 10)
+			assert.NoError(t, err)
+			assert.Equal(t, i+10, r)
+		}
+		s := pool.Stats()
+		assert.Equal(t, 1, s.Dialed)
+		assert.Equal(t, 0, s.Evicted)
+	})
+
+	t.Run("closed idle flow is redialled", func(t *testing.T) {
+		mu.Lock()
+		for _, server := range servers {
+			_ = server.Close()
+		}
+		mu.Unlock()
+		r, err := Query[int](conn, "sum", 2, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, 4, r)
+		s := pool.Stats()
+		assert.Equal(t, 2, s.Dialed)
+		assert.Equal(t, 1, s.Evicted)
+		assert.Equal(t, 1, s.Open)
+	})
+
+	t.Run("concurrent calls are bounded", func(t *testing.T) {
+		wg := sync.WaitGroup{}
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				r, err := Query[int](conn, "sum", i, i)
+				assert.NoError(t, err)
+				assert.Equal(t, 2*i, r)
+			}(i)
+		}
+		wg.Wait()
+		s := pool.Stats()
+		assert.LessOrEqual(t, s.Open, 2)
+		assert.Equal(t, s.Open, s.Idle)
+	})
+
+	t.Run("closed pool", func(t *testing.T) {
+		assert.NoError(t, pool.Close())
+		assert.Equal(t, 0, pool.Stats().Open)
+		_, err := Query[int](conn, "sum", 1, 1)
+		assert.ErrorIs(t, err, ErrPoolClosed)
+	})
+}
@@ -1,7 +1,6 @@
 package jrpc
 
 import (
-	"bufio"
 	"context"
 	"errors"
 	"fmt"
@@ -10,23 +9,33 @@ import (
 	"log"
 	"reflect"
 	"strings"
+	"time"
 	"unicode"
 )
 
 type Router struct {
-	logger        *log.Logger
-	registry      *Registry[*Caller]
-	routes        []string
-	env           []any
-	port          string
-	query         string
-	args          string
-	rpc           *Flow
-	registerRoute func(ctx context.Context, route string) error
+	logger    *log.Logger
+	registry  *Registry[*Caller]
+	routes    []string
+	env       []any
+	port      string
+	query     string
+	args      string
+	rpc       *Flow
+	listener  Listener
+	codecs    Codecs
+	decoders  []ArgsDecoder
+	intercept []Interceptor
+	supported []Codec
+	jsonrpc   bool
+	debug     bool
+	hangup    func()
+	timeout   time.Duration
+	authorize func(r *Router, ctx context.Context, remoteID id.Identity, query any) bool
 }
 
-var ErrMalformedRequest = errors.New("malformed request")
-var ErrUnauthorized = errors.New("unauthorized")
+var ErrMalformedRequest = RegisterError("malformed_request", errors.New("malformed request"))
+var ErrUnauthorized = RegisterError("unauthorized", errors.New("unauthorized"))
 
 func NewRouter(port string) *Router {
 	return &Router{
@@ -45,6 +54,17 @@ func (r *Router) Logger(logger *log.Logger) *Router {
 	return r
 }
 
+// Debug sends the stack of handler panics to clients along with the error.
+func (r *Router) Debug() *Router {
+	r.debug = true
+	return r
+}
+
+func (r *Router) Listener(listener Listener) *Router {
+	r.listener = listener
+	return r
+}
+
 func (r *Router) With(env ...any) *Router {
 	rr := *r
 	rr.env = append(r.env, env...)
@@ -80,29 +100,67 @@ func (r *Router) Interface(srv any) *Router {
 }
 
 func (r *Router) Run(ctx context.Context) (err error) {
+	if r.listener == nil {
+		return ErrNoListener
+	}
 	r.registerApi()
+	var routes []string
 	if len(r.routes) == 0 {
-		go func(r Router, route string) {
-			if err = r.registerRoute(ctx, route); err != nil {
-				panic(err)
-			}
-		}(*r, r.port)
+		routes = append(routes, r.port)
 	}
 	for _, cmd := range r.routes {
-		rr := *r
 		f := "%s.%s"
 		if cmd == "*" {
 			f = "%s%s"
 		}
-		go func(r Router, route string) {
-			if err = r.registerRoute(ctx, route); err != nil {
-				panic(err)
-			}
-		}(rr, fmt.Sprintf(f, r.port, cmd))
+		routes = append(routes, fmt.Sprintf(f, r.port, cmd))
+	}
+	// the routes registered before a failed one are unregistered
+	ctx, cancel := context.WithCancel(ctx)
+	defer func() {
+		if err != nil {
+			cancel()
+		}
+	}()
+	for _, route := range routes {
+		var queries <-chan IncomingQuery
+		if queries, err = r.listener.Listen(ctx, route); err != nil {
+			return
+		}
+		go r.serve(ctx, queries)
 	}
 	return
 }
 
+func (r *Router) serve(ctx context.Context, queries <-chan IncomingQuery) {
+	for query := range queries {
+		go func(query IncomingQuery) {
+			_ = r.routeQuery(ctx, query)
+		}(query)
+	}
+}
+
+func (r *Router) routeQuery(ctx context.Context, query IncomingQuery) (err error) {
+	// setup
+	rr := r.Query(query.Query())
+	if rr.registry.IsEmpty() && query.Query() != rr.port {
+		return query.Reject()
+	}
+
+	// authorize
+	if !rr.authorized(ctx, query.RemoteIdentity(), query) {
+		return query.Reject()
+	}
+
+	// accept
+	conn, err := query.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	return rr.Handle(ctx, query, query.RemoteIdentity(), conn)
+}
+
 func (r *Router) registerApi() *Router {
 	var arr []string
 	for s := range r.registry.All() {
@@ -120,9 +178,12 @@ func (r *Router) Query(query string) *Router { return r.shift(query, false) }
 
 func (r *Router) shift(query string, force bool) *Router {
 	rr := *r
-	rr.Conn(rr.rpc)
+	if rr.rpc != nil {
+		rr.Conn(rr.rpc)
+	}
 	rr.query = strings.TrimPrefix(query, r.port)
 	rr.query = strings.TrimPrefix(rr.query, ".")
+	rr.timeout, rr.query = cutTimeout(rr.query)
 	rr.registry, rr.args = r.registry.Unfold(rr.query)
 	rr.port = strings.TrimSuffix(rr.query, rr.args)
 
@@ -147,107 +208,307 @@ func (r *Router) Authorize(ctx context.Context, query any) bool {
 	return len(res) == 0 || res[0] != false
 }
 
+func (r *Router) authorized(ctx context.Context, remoteID id.Identity, query any) bool {
+	if r.authorize != nil {
+		return r.authorize(r, ctx, remoteID, query)
+	}
+	return r.Authorize(ctx, query)
+}
+
 func (r *Router) Handle(ctx context.Context, query any, remoteId id.Identity, conn io.ReadWriteCloser) (err error) {
+	r = r.With() // keep the connection state out of the router
+	if r.jsonrpc {
+		return r.handleJsonRpc(ctx, query, remoteId, conn)
+	}
 	r.Conn(conn)
+	reader := newHangupReader(conn)
+	defer reader.stop()
+	r.rpc.ByteScannerReader = NewByteScannerReader(reader)
+	w := &responder{rpc: r.rpc}
+	defer w.Wait()
+	// calls are cancelled when the connection is done
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 	rr := *r
-	scanner := bufio.NewScanner(conn)
 	var result []any
+	var command string
+	var requestId uint64
+	first := true
 	for {
 		switch {
+		case !rr.registry.IsEmpty() && requestId != 0:
+			// caller found, run concurrently
+			var call func() ([]any, error)
+			timeoutCtx, stop := rr.withTimeout(ctx)
+			callCtx, release := w.context(timeoutCtx, requestId)
+			if call, err = rr.With(callCtx, query, remoteId, rr.rpc).bind(); err != nil {
+				release()
+				stop()
+				if !rr.respondWith(ctx, w.encoder(requestId), err) {
+					return
+				}
+				break
+			}
+			w.Add(1)
+			go func(rr Router, requestId uint64) {
+				defer w.Done()
+				defer stop()
+				defer release()
+				result, err := call()
+				result, err = deadline(callCtx, result, err)
+				rr.respondTagged(callCtx, w, requestId, err, result...)
+			}(rr, requestId)
+
 		case !rr.registry.IsEmpty():
 			// caller found
-			result, err = rr.With(ctx, query, remoteId, rr.rpc).Call()
-			if !rr.respond(ctx, err, result...) {
+			callCtx, cancelCall := rr.withTimeout(ctx)
+			rr.hangup = cancelCall
+			reader.watch(cancelCall)
+			result, err = rr.With(callCtx, query, remoteId, rr.rpc).Call()
+			result, err = deadline(callCtx, result, err)
+			ok := rr.respondWith(callCtx, w.encoder(0), err, result...)
+			reader.watch(nil)
+			cancelCall()
+			if !ok {
 				return
 			}
 
 		case rr.args != "":
 			// caller not found and there are unhandled data in rpc buffer
-			if !rr.respond(ctx, ErrMalformedRequest) {
+			if !rr.respondWith(ctx, w.encoder(requestId), ErrMalformedRequest) {
 				return
 			}
 		}
 
-		r.rpc.Clear()
-		if !scanner.Scan() {
+		if command, err = r.nextCommand(first); err != nil {
+			if errors.Is(err, io.EOF) {
+				err = nil
+			}
 			return
 		}
-		rr = *r.Query(scanner.Text())
+		first = false
+		requestId, command = cutRequestId(command)
+		if requestId != 0 && command == "" {
+			// tagged command without method cancels the call
+			w.cancel(requestId)
+			rr = *r
+			rr.registry, rr.args = NewRegistry[*Caller](), ""
+			continue
+		}
+		rr = *r.Query(command)
+		rr.rpc = r.rpc
 
 		//authorize if registry changed
-		if rr.registry.value != r.registry.value && !rr.Authorize(ctx, query) {
-			if !rr.respond(ctx, ErrUnauthorized) {
+		if rr.registry.value != r.registry.value && !rr.authorized(ctx, remoteId, query) {
+			if !rr.respondWith(ctx, w.encoder(requestId), ErrUnauthorized) {
 				return
 			}
+			// skip the call
+			rr.registry, rr.args = NewRegistry[*Caller](), ""
 		}
 	}
 }
 
+// nextCommand reads the next command from the connection. The first command
+// can be the codec handshake, which is handled in place.
+func (r *Router) nextCommand(first bool) (command string, err error) {
+	r.rpc.feeding.Wait()
+	if c, ok := r.rpc.ByteScannerReader.(compacter); ok {
+		c.Compact()
+	}
+	if command, err = readCommand(r.rpc); err != nil || !first {
+		return
+	}
+	args, ok := strings.CutPrefix(command, CodecMethod)
+	if !ok || args != "" && args[0] != '[' {
+		return
+	}
+	if err = r.negotiate(args); err != nil {
+		return
+	}
+	return r.nextCommand(false)
+}
+
+// readCommand reads the next line from the scanner. Binary arguments
+// can contain new line bytes, so reading stops before the first byte
+// that cannot be a part of UTF-8 text and the arguments are left for
+// the decoder.
+func readCommand(scanner io.ByteScanner) (command string, err error) {
+	var line []byte
+	var b byte
+	continuation := 0
+	for len(line) <= maxQueryLen {
+		if b, err = scanner.ReadByte(); err != nil {
+			if errors.Is(err, io.EOF) && len(line) > 0 {
+				err = nil
+				break
+			}
+			return
+		}
+		switch {
+		case b == '\n':
+			return strings.TrimSuffix(string(line), "\r"), nil
+		case b >= 0xf8:
+			continuation = 0
+		case b >= 0xf0:
+			continuation = 3
+		case b >= 0xe0:
+			continuation = 2
+		case b >= 0xc0:
+			continuation = 1
+		case b >= 0x80 && continuation > 0:
+			continuation--
+		case b >= 0x80 && len(line) > 0:
+			err = scanner.UnreadByte()
+			return string(line), err
+		}
+		line = append(line, b)
+	}
+	if len(line) > maxQueryLen {
+		return "", ErrQueryTooLong
+	}
+	return string(line), nil
+}
+
+func (r *Router) Codecs(codecs Codecs) *Router {
+	r.codecs = codecs
+	return r
+}
+
+// Decoders sets arguments decoders preferred over the ones registered in callers.
+func (r *Router) Decoders(decoders ...ArgsDecoder) *Router {
+	r.decoders = decoders
+	return r
+}
+
+// Use adds interceptors running around the calls of all methods, before the
+// interceptors of callers.
+func (r *Router) Use(interceptors ...Interceptor) *Router {
+	r.intercept = append(r.intercept, interceptors...)
+	return r
+}
+
 func (r *Router) Conn(conn io.ReadWriteCloser) *Router {
 	r.rpc = NewFlow(conn)
+	if r.codecs != nil {
+		r.rpc.Codecs(r.codecs)
+	}
 	if r.logger != nil {
 		r.rpc.Logger(r.logger)
 	}
 	return r
 }
 
+// bind decodes the arguments and returns the call to run later.
+func (r *Router) bind() (call func() ([]any, error), err error) {
+	args := r.loadArgs()
+	if r.registry.IsEmpty() {
+		return nil, fmt.Errorf("route not found for query %s%s ", r.port, r.args)
+	}
+	caller := r.registry.Get().With(r.env...)
+	caller.decoder = caller.decoder.Prepend(r.decoders)
+	caller.interceptors = interceptors(r.intercept, caller.interceptors)
+	caller.logger, caller.debug = r.logger, r.debug
+	return caller.Bind(args)
+}
+
 func (r *Router) Call() (result []any, err error) {
-	r.loadArgs()
+	args := r.loadArgs()
 	if r.registry.IsEmpty() {
 		return nil, fmt.Errorf("route not found for query %s%s ", r.port, r.args)
 	}
-	result, err = r.registry.Get().With(r.env...).Call(r.rpc)
+	caller := r.registry.Get().With(r.env...)
+	caller.decoder = caller.decoder.Prepend(r.decoders)
+	caller.interceptors = interceptors(r.intercept, caller.interceptors)
+	caller.logger, caller.debug = r.logger, r.debug
+	if r.rpc != nil && !r.jsonrpc {
+		caller.input = r.rpc.Serializer
+	}
+	caller.hangup = r.hangup
+	result, err = caller.Call(args)
 	return
 }
 
-func (r *Router) loadArgs() {
-	if r.rpc != nil && r.args != "" {
+// loadArgs returns a reader of arguments passed within the query,
+// or the connection if there are none so they can be read from the stream.
+// Without connection there are no arguments to read.
+func (r *Router) loadArgs() (args ByteScannerReader) {
+	args = NewByteScannerReader(strings.NewReader(""))
+	if r.rpc != nil {
+		args = r.rpc
+	}
+	if r.args != "" {
 		if !strings.HasSuffix(r.args, "\n") {
 			r.args += "\n"
 		}
-		r.rpc.Append([]byte(r.args))
+		args = NewByteScannerReader(strings.NewReader(r.args))
 	}
 	r.args = ""
+	return
 }
 
 func (r *Router) respond(ctx context.Context, err error, result ...any) (b bool) {
+	return r.respondWith(ctx, func(value any) error { return encodeResponse(r.rpc, value) }, err, result...)
+}
+
+func (r *Router) respondWith(ctx context.Context, encode func(any) error, err error, result ...any) (b bool) {
 
 	// eof / error / empty / arr
 	switch {
 	case errors.Is(err, io.EOF):
 		return false
 	case err != nil:
-		return r.rpc.Encode(err) == nil
+		return encode(err) == nil
 	case len(result) == 0:
-		return r.rpc.Encode(EmptyResponse) == nil
+		return encode(EmptyResponse) == nil
 	case len(result) > 1:
-		return r.rpc.Encode(result) == nil
+		return encode(result) == nil
 	}
 
 	res := result[len(result)-1]
-	v := reflect.ValueOf(res)
+	v, streamErr, ok := streamOf(res)
+
+	// raw bytes
+	if reader, isReader := res.(io.Reader); !ok && isReader {
+		return r.respondReader(ctx, encode, reader)
+	}
 
 	// single
-	if v.Kind() != reflect.Chan {
-		return r.rpc.Encode(res) == nil
+	if !ok {
+		return encode(res) == nil
 	}
 
 	// channel
-	sel := []reflect.SelectCase{{Dir: reflect.SelectRecv, Chan: v}}
+	sel := []reflect.SelectCase{
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())},
+		{Dir: reflect.SelectRecv, Chan: v},
+	}
 	for {
-		select {
-		case <-ctx.Done():
-			return
-		default:
-			if _, v, b = reflect.Select(sel); !b {
-				return
-			}
-			res = v.Interface()
-			if err = r.rpc.Encode(res); err != nil {
-				return false
+		var i int
+		if i, v, b = reflect.Select(sel); i == 0 {
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				_ = encode(ctx.Err())
 			}
+			return false
+		}
+		if !b {
+			break
+		}
+		res = v.Interface()
+		if err = encode(res); err != nil {
+			return false
+		}
+	}
+
+	// end of stream frame
+	if streamErr != nil {
+		if err = streamErr(); err != nil {
+			_ = encode(err)
+		} else {
+			_ = encode(EndOfStream)
 		}
 	}
+	return false
 }
 
 var EmptyResponse = struct{}{}
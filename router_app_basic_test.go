@@ -1,4 +1,4 @@
-package jrpc
+package jrpc_test
 
 import (
 	"context"
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"github.com/cryptopunkscc/astrald/auth/id"
 	"github.com/cryptopunkscc/astrald/mod/apphost/proto"
+	"github.com/cryptopunkscc/go-apphost-jrpc"
 	"github.com/stretchr/testify/assert"
 	"log"
 	"reflect"
@@ -26,14 +27,14 @@ func TestApp_Run_basic(t *testing.T) {
 	}
 
 	port := "test_app"
-	clients := []func(*testing.T) (Conn, error){
-		func(*testing.T) (c Conn, err error) {
-			c = NewRequest(id.Anyone, port)
+	clients := []func(*testing.T) (jrpc.Conn, error){
+		func(*testing.T) (c jrpc.Conn, err error) {
+			c = jrpc.NewRequest(id.Anyone, port)
 			c.Logger(log.New(log.Writer(), "", 0))
 			return
 		},
-		func(t *testing.T) (c Conn, err error) {
-			c, err = QueryFlow(id.Anyone, port)
+		func(t *testing.T) (c jrpc.Conn, err error) {
+			c, err = jrpc.QueryFlow(id.Anyone, port)
 			if err != nil {
 				return
 			}
@@ -41,7 +42,7 @@ func TestApp_Run_basic(t *testing.T) {
 			return
 		},
 	}
-	setHandlers := func(app *App) {
+	setHandlers := func(app *jrpc.App) {
 		app.Func("func0", function0)
 		app.Func("func0!", function0)
 		app.Func("func1", function1)
@@ -92,9 +93,9 @@ func TestApp_Run_basic(t *testing.T) {
 		client   int
 	}{
 		{query: "asd", expected: proto.ErrRejected, client: 1},
-		{query: "asd", expected: ErrMalformedRequest, client: 2},
+		{query: "asd", expected: jrpc.ErrMalformedRequest, client: 2},
 		{query: "func0", expected: proto.ErrRejected, client: 1},
-		{query: "func0", expected: ErrUnauthorized, client: 2},
+		{query: "func0", expected: jrpc.ErrUnauthorized, client: 2},
 		{query: "func1", expected: map[string]any{}},
 		{query: "func2[1]", expected: float64(1)},
 		{query: "func2 1", expected: float64(1)},
@@ -154,7 +155,7 @@ func TestApp_Run_basic(t *testing.T) {
 
 	for i1, r := range routes {
 		t.Run(fmt.Sprintf("routes:%d", i1+1), func(t *testing.T) {
-			app := NewApp(port)
+			app := jrpc.NewApp(port)
 			app.Routes(r...)
 			setHandlers(app)
 			ctx, cancel := context.WithCancel(context.Background())
@@ -183,7 +184,7 @@ func TestApp_Run_basic(t *testing.T) {
 							if tt.arg != nil {
 								args = append([]any{tt.arg}, args...)
 							}
-							if err := Call(client, tt.query, args...); err != nil {
+							if err := jrpc.Call(client, tt.query, args...); err != nil {
 								skip(t, i1, i2, i3, err)
 								assert.Equal(t, tt.expected, err)
 								return
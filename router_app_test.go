@@ -1,19 +1,27 @@
-package jrpc
+package jrpc_test
 
 import (
 	"context"
 	"github.com/cryptopunkscc/astrald/auth/id"
+	"github.com/cryptopunkscc/go-apphost-jrpc"
+	"github.com/cryptopunkscc/go-apphost-jrpc/jrpctest"
 	"github.com/stretchr/testify/assert"
 	"log"
+	"os"
 	"testing"
 	"time"
 )
 
+func TestMain(m *testing.M) {
+	jrpc.DefaultApphost = jrpctest.New()
+	os.Exit(m.Run())
+}
+
 func TestApp_Run(t *testing.T) {
 
 	// register service
 	ctx := context.Background()
-	app := NewApp("testApi")
+	app := jrpc.NewApp("testApi")
 	app.Routes("*")
 	app.Func("test", func(i int, b bool) int {
 		log.Println("test args", i, b)
@@ -29,19 +37,19 @@ func TestApp_Run(t *testing.T) {
 	}
 	time.Sleep(10 * time.Millisecond)
 
-	conn, _ := QueryFlow(id.Identity{}, "testApi")
-	//conn := NewRequest(id.Identity{}, "testApi")
+	conn, _ := jrpc.QueryFlow(id.Identity{}, "testApi")
+	//conn := jrpc.NewRequest(id.Identity{}, "testApi")
 	conn.Logger(log.New(log.Writer(), "client ", 0))
 
 	t.Run("Query invalid", func(t *testing.T) {
-		err := Command(conn, "asdasdas \n")
+		err := jrpc.Command(conn, "asdasdas \n")
 		if err == nil {
 			t.Fatal()
 		}
 	})
 
 	t.Run("Query with correct args", func(t *testing.T) {
-		r, err := Query[int](conn, "test", 1, true)
+		r, err := jrpc.Query[int](conn, "test", 1, true)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -49,35 +57,35 @@ func TestApp_Run(t *testing.T) {
 	})
 
 	t.Run("Query with incorrect args", func(t *testing.T) {
-		r, err := Query[int](conn, "test asd\n")
+		r, err := jrpc.Query[int](conn, "test asd\n")
 		assert.Error(t, err, r)
 
-		r, err = Query[int](conn, "asdasdas\n")
+		r, err = jrpc.Query[int](conn, "asdasdas\n")
 		log.Println(err)
 		assert.Error(t, err, r)
 
-		r, err = Query[int](conn, "testasdasdas\n")
+		r, err = jrpc.Query[int](conn, "testasdasdas\n")
 		log.Println(err)
 		assert.Error(t, err, r)
 
-		r, err = Query[int](conn, "test asd\n")
+		r, err = jrpc.Query[int](conn, "test asd\n")
 		assert.Error(t, err, r)
 
-		r, err = Query[int](conn, "testasdasdas\n")
+		r, err = jrpc.Query[int](conn, "testasdasdas\n")
 		assert.Error(t, err, r)
 
-		r, err = Query[int](conn, "test asd\n")
+		r, err = jrpc.Query[int](conn, "test asd\n")
 		assert.Error(t, err, r)
 
 	})
 
 	t.Run("Query string with newline", func(t *testing.T) {
-		r, err := Query[string](conn, "test2", "hello \n world")
+		r, err := jrpc.Query[string](conn, "test2", "hello \n world")
 		if err != nil {
 			t.Fatal(err)
 		}
 		log.Println(r)
-		r, err = Query[string](conn, "test2", "hello \n world")
+		r, err = jrpc.Query[string](conn, "test2", "hello \n world")
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -90,7 +98,7 @@ func TestApp_Run_root(t *testing.T) {
 	t.Cleanup(func() {
 		cancel()
 	})
-	app := NewApp("test")
+	app := jrpc.NewApp("test")
 	app.Logger(log.New(log.Writer(), "service ", 0))
 	app.Func("", func(_, identity id.Identity) bool {
 		return identity.IsEqual(id.Anyone)
@@ -100,7 +108,7 @@ func TestApp_Run_root(t *testing.T) {
 		t.Fatal(err)
 	}
 	time.Sleep(1000000)
-	rpc, err := QueryFlow(id.Anyone, "test")
+	rpc, err := jrpc.QueryFlow(id.Anyone, "test")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -118,7 +126,7 @@ func TestApp_Run_root(t *testing.T) {
 		{otherID, false},
 	}
 	for _, expected := range tests {
-		b, err := Query[bool](rpc, "", expected.id)
+		b, err := jrpc.Query[bool](rpc, "", expected.id)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -129,14 +137,14 @@ func TestApp_Run_root(t *testing.T) {
 
 func TestApp_Run_subroutine(t *testing.T) {
 	ctx := context.Background()
-	app := NewApp("test")
+	app := jrpc.NewApp("test")
 	app.Logger(log.New(log.Writer(), "service ", 0))
 	app.Func("a", func() (i int, err error) {
-		conn, err := QueryFlow(id.Anyone, "test2")
+		conn, err := jrpc.QueryFlow(id.Anyone, "test2")
 		if err != nil {
 			return
 		}
-		i, err = Query[int](conn, "b")
+		i, err = jrpc.Query[int](conn, "b")
 		return
 	})
 	err := app.Run(ctx)
@@ -144,7 +152,7 @@ func TestApp_Run_subroutine(t *testing.T) {
 		t.Fatal(err)
 	}
 	time.Sleep(1000000)
-	rpc, err := QueryFlow(id.Anyone, "test")
+	rpc, err := jrpc.QueryFlow(id.Anyone, "test")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -153,7 +161,7 @@ func TestApp_Run_subroutine(t *testing.T) {
 	})
 	rpc.Logger(log.New(log.Writer(), "  client ", 0))
 
-	app = NewApp("test2")
+	app = jrpc.NewApp("test2")
 	app.Logger(log.New(log.Writer(), "service2 ", 0))
 	app.Func("b", func() int {
 		return 1
@@ -164,7 +172,7 @@ func TestApp_Run_subroutine(t *testing.T) {
 	}
 	time.Sleep(1000000)
 
-	i, err := Query[int](rpc, "a")
+	i, err := jrpc.Query[int](rpc, "a")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -0,0 +1,30 @@
+package jrpc
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRouter_Handle_unauthorized(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	called := atomic.Bool{}
+	r := NewRouter("test")
+	r.Func("secret", func() int { called.Store(true); return 1 })
+	r.Func("secret!", func() bool { return false })
+	r.Func("sum", func(a, b int) int { return a + b })
+
+	conn := NewFlow(serve(ctx, t, r))
+
+	_, err := Query[int](conn, "secret")
+	assert.ErrorContains(t, err, ErrUnauthorized.Error())
+
+	// the denied call is skipped, so the next response belongs to the next call
+	sum, err := Query[int](conn, "sum", 1, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, sum)
+	assert.False(t, called.Load())
+}
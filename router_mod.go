@@ -3,7 +3,6 @@ package jrpc
 import (
 	"context"
 	"github.com/cryptopunkscc/astrald/auth/id"
-	"github.com/cryptopunkscc/astrald/net"
 	"github.com/cryptopunkscc/astrald/node"
 )
 
@@ -12,50 +11,23 @@ type Module struct {
 	node node.Node
 }
 
-func NewModule(node node.Node, port string) (r *Module) {
-	r = &Module{Router: *NewRouter(port), node: node}
-	r.Router.registerRoute = r.registerRoute
+func NewModule(node node.Node, port string) (m *Module) {
+	m = &Module{Router: *NewRouter(port), node: node}
+	m.Listener(NewModuleListener(node))
+	m.Router.authorize = m.authorize
 	return
 }
 
-func (m Module) registerRoute(ctx context.Context, route string) (err error) {
-	if err = m.node.LocalRouter().AddRoute(route, m); err != nil {
-		return
+func (m *Module) authorize(r *Router, ctx context.Context, callerID id.Identity, query any) bool {
+	res, _ := r.Command("!").With(ctx, query).Call()
+	if len(res) == 0 {
+		return true
 	}
-	go func() {
-		<-ctx.Done()
-		_ = m.node.LocalRouter().RemoveRoute(route)
-	}()
-	return
-}
-
-func (m Module) RouteQuery(ctx context.Context, query net.Query, caller net.SecureWriteCloser, hints net.Hints) (s net.SecureWriteCloser, err error) {
-	// setup
-	m.Router = *m.Query(query.Query())
-	if m.registry.IsEmpty() && query.Query() != m.port {
-		return nil, net.ErrRejected
-	}
-
-	// authorize
-	if m.authorize(ctx, query.Caller(), query) {
-		return nil, net.ErrRejected
-	}
-
-	// accept
-	return net.Accept(query, caller, func(conn net.SecureConn) {
-		_ = m.Handle(ctx, query, query.Caller(), conn)
-	})
-}
-
-func (m Module) authorize(ctx context.Context, callerID id.Identity, query any) bool {
-	res, _ := m.Command("!").With(ctx, query).Call()
-	if len(res) > 0 {
-		switch v := res[0].(type) {
-		case bool:
-			return v
-		case string:
-			return m.node.Auth().Authorize(callerID, v)
-		}
+	switch v := res[0].(type) {
+	case bool:
+		return v
+	case string:
+		return m.node.Auth().Authorize(callerID, v)
 	}
 	return false
 }
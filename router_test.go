@@ -1,10 +1,13 @@
 package jrpc
 
 import (
+	"bytes"
 	"context"
 	"github.com/stretchr/testify/assert"
 	"log"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestRouter(t *testing.T) {
@@ -30,6 +33,26 @@ type testRouterStruct struct {
 	S string `json:"s" pos:"2"`
 }
 
+func TestRouter_Run(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	mux := &QueryMux{}
+	if _, err := mux.Listen(ctx, "test.taken"); err != nil {
+		t.Fatal(err)
+	}
+	r := NewRouter("test").Listener(mux).Routes("sum", "taken")
+	r.Func("sum", func(a, b int) int { return a + b })
+	r.Func("taken", func() {})
+	assert.ErrorIs(t, r.Run(ctx), ErrRouteTaken)
+
+	// the route registered before the failed one is released
+	assert.Eventually(t, func() bool {
+		_, err := mux.Listen(ctx, "test.sum")
+		return err == nil
+	}, time.Second, time.Millisecond)
+}
+
 func TestRouter2(t *testing.T) {
 	r := NewRouter("")
 	f := func(arg testRouterStruct) testRouterStruct {
@@ -69,3 +92,144 @@ func TestRouter2(t *testing.T) {
 		}
 	})
 }
+
+func TestRouter_Panic(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	logs := &syncBuffer{}
+	r := NewRouter("test").Logger(log.New(logs, "", 0))
+	r.Func("boom", func(n int) int { panic("boom") })
+	r.Func("sum", func(a, b int) int { return a + b })
+	r.Func("decode", func(a panicArg) int { return 0 })
+
+	t.Run("error response", func(t *testing.T) {
+		conn := NewFlow(serve(ctx, t, r))
+		_, err := Query[int](conn, "boom", 1)
+		var e *Error
+		assert.ErrorIs(t, err, ErrPanic)
+		assert.ErrorAs(t, err, &e)
+		assert.Equal(t, CodeInternal, e.Code)
+		assert.Equal(t, "panic: boom", e.Message)
+		assert.Nil(t, e.Data)
+		assert.Contains(t, logs.String(), "panic in boom: boom")
+
+		// the connection is still served
+		sum, err := Query[int](conn, "sum", 1, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, sum)
+	})
+
+	t.Run("tagged", func(t *testing.T) {
+		_, err := Query[int](NewMuxFlow(serve(ctx, t, r)), "boom", 1)
+		assert.ErrorIs(t, err, ErrPanic)
+	})
+
+	t.Run("debug", func(t *testing.T) {
+		_, err := Query[int](NewFlow(serve(ctx, t, r.With().Debug())), "boom", 1)
+		var e *Error
+		assert.ErrorAs(t, err, &e)
+		assert.Contains(t, e.Data, "runtime/debug.Stack")
+	})
+
+	t.Run("decoding args", func(t *testing.T) {
+		conn := NewFlow(serve(ctx, t, r))
+		_, err := Query[int](conn, "decode", 1)
+		assert.ErrorIs(t, err, ErrPanic)
+		assert.Contains(t, logs.String(), "panic in decode: unmarshal")
+
+		sum, err := Query[int](conn, "sum", 1, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, sum)
+
+		_, err = Query[int](NewMuxFlow(serve(ctx, t, r)), "decode", 1)
+		assert.ErrorIs(t, err, ErrPanic)
+	})
+}
+
+// panicArg panics when decoded from JSON.
+type panicArg struct{}
+
+func (panicArg) UnmarshalJSON([]byte) error { panic("unmarshal") }
+
+// syncBuffer is a buffer safe for concurrent writes of loggers.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestRouter_Disconnect(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	stopped := make(chan string, 1)
+	r := NewRouter("test")
+	r.Func("ticks", func(ctx context.Context) <-chan int {
+		c := make(chan int)
+		go func() {
+			defer close(c)
+			for i := 0; ; i++ {
+				select {
+				case c <- i:
+				case <-ctx.Done():
+					stopped <- "ticks"
+					return
+				}
+			}
+		}()
+		return c
+	})
+	r.Func("store", func(ctx context.Context, in <-chan int) int {
+		<-ctx.Done()
+		stopped <- "store"
+		return 0
+	})
+	r.Func("wait", func(ctx context.Context) int {
+		<-ctx.Done()
+		stopped <- "wait"
+		return 0
+	})
+
+	wait := func(t *testing.T, name string) {
+		select {
+		case s := <-stopped:
+			assert.Equal(t, name, s)
+		case <-time.After(time.Second):
+			t.Fatal("call not cancelled")
+		}
+	}
+
+	t.Run("stream", func(t *testing.T) {
+		s, err := SubscribeStream[int](NewFlow(serve(ctx, t, r)), "ticks")
+		assert.NoError(t, err)
+		assert.Equal(t, 0, <-s.Values())
+		s.Close()
+		wait(t, "ticks")
+	})
+
+	t.Run("stream argument", func(t *testing.T) {
+		conn := NewFlow(serve(ctx, t, r))
+		assert.NoError(t, Call(conn, "store"))
+		assert.NoError(t, conn.Close())
+		wait(t, "store")
+	})
+
+	t.Run("single value", func(t *testing.T) {
+		conn := NewFlow(serve(ctx, t, r))
+		assert.NoError(t, Call(conn, "wait"))
+		assert.NoError(t, conn.Close())
+		wait(t, "wait")
+	})
+}
@@ -30,6 +30,20 @@ func (r *byteScannerReader) Clear() {
 	r.end = 0
 }
 
+// compacter is implemented by scanners able to drop the consumed bytes
+// from the buffer.
+type compacter interface{ Compact() }
+
+func (r *byteScannerReader) Compact() {
+	if r.offset == 0 {
+		return
+	}
+	buff := r.buff[:cap(r.buff)]
+	n := copy(buff, buff[r.offset:r.end])
+	r.offset = 0
+	r.end = n
+}
+
 func (r *byteScannerReader) Buffer() []byte {
 	return r.buff
 }
@@ -80,7 +94,7 @@ func (r *byteScannerReader) Read(p []byte) (n int, err error) {
 		r.buff = nil
 		return r.Reader.Read(p)
 	}
-	for n = 0; n < cap(p) && r.offset < r.end; n++ {
+	for n = 0; n < len(p) && r.offset < r.end; n++ {
 		p[n] = r.buff[r.offset]
 		r.offset++
 	}
@@ -27,3 +27,19 @@ func TestByteScannerReader(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestByteScannerReader_Compact(t *testing.T) {
+	scanner := NewByteScannerReader(strings.NewReader("ab"))
+	if _, err := scanner.ReadByte(); err != nil {
+		t.Fatal(err)
+	}
+	scanner.(compacter).Compact()
+	b, err := scanner.ReadByte()
+	assert.NoError(t, err)
+	assert.Equal(t, byte('b'), b)
+
+	// scanners implemented outside of the package are not required to compact
+	var other ByteScannerReader = struct{ ByteScannerReader }{scanner}
+	_, ok := other.(compacter)
+	assert.False(t, ok)
+}
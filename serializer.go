@@ -1,11 +1,11 @@
 package jrpc
 
 import (
-	"errors"
 	"github.com/cryptopunkscc/astrald/auth/id"
 	"io"
 	"log"
 	"reflect"
+	"sync"
 )
 
 type Serializer struct {
@@ -18,6 +18,7 @@ type Serializer struct {
 	unmarshal Unmarshal
 	remoteID  id.Identity
 	codecs    Codecs
+	feeding   sync.WaitGroup
 }
 
 type Encoder interface{ Encode(v any) error }
@@ -34,6 +35,7 @@ func (s *Serializer) RemoteIdentity() (i id.Identity) {
 
 func (s *Serializer) Codecs(codecs Codecs) {
 	s.codecs = codecs
+	s.setupEncoding()
 }
 
 func (s *Serializer) Logger(logger *log.Logger) {
@@ -81,7 +83,7 @@ func (s *Serializer) Encode(value any) (err error) {
 	r := value
 	switch v := value.(type) {
 	case error:
-		r = Failure{v.Error()}
+		r = newFailure(v)
 	}
 	return s.enc.Encode(r)
 }
@@ -92,13 +94,61 @@ func (s *Serializer) Decode(value any) (err error) {
 	if err = s.dec.Decode(&r); err != nil {
 		return
 	}
+	return s.decodeRaw(r.bytes, value)
+}
 
-	// try decode as failure
-	f := Failure{}
-	if err = s.unmarshal(r.bytes, &f); err == nil && f.Error != "" {
-		return errors.New(f.Error)
+func (s *Serializer) decodeRaw(bytes []byte, value any) (err error) {
+	value, remote := unwrapRemote(value)
+
+	// try decode as failure or end of stream
+	f := frame{}
+	if err = s.unmarshal(bytes, &f); err == nil {
+		switch {
+		case f.Error != "":
+			*remote = true
+			return f.err()
+		case f.Stream == EndOfStream.Stream:
+			return ErrEndOfStream
+		}
 	}
 
 	// decode value
-	return s.unmarshal(r.bytes, value)
+	return s.unmarshal(bytes, value)
+}
+
+// valueDecoder returns a decoder of values sent after the request on the
+// connection. The values are read byte by byte, so the data following them
+// is left in place for the next request.
+func (s *Serializer) valueDecoder() *Serializer {
+	codecs := s.codecs
+	if codecs == nil {
+		codecs = JsonCodecs
+	}
+	v := &Serializer{ByteScannerReader: s.ByteScannerReader, codecs: codecs}
+	rw := struct {
+		io.Reader
+		io.Writer
+	}{byteReader{s.ByteScannerReader}, io.Discard}
+	_, v.dec, v.marshal, v.unmarshal = codecs(rw)
+	return v
+}
+
+// byteReader reads a single byte at once.
+type byteReader struct{ io.ByteReader }
+
+func (r byteReader) Read(p []byte) (n int, err error) {
+	if len(p) == 0 {
+		return
+	}
+	if p[0], err = r.ReadByte(); err != nil {
+		return
+	}
+	return 1, nil
+}
+
+// skipNewline consumes the new line following a value.
+func skipNewline(r io.ByteScanner) {
+	if b, err := r.ReadByte(); err == nil && b != '\n' {
+		_ = r.UnreadByte()
+	}
 }
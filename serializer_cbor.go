@@ -0,0 +1,23 @@
+package jrpc
+
+import (
+	"github.com/fxamacker/cbor/v2"
+	"io"
+)
+
+func CborCodecs(rw io.ReadWriter) (e Encoder, d Decoder, m Marshal, u Unmarshal) {
+	e = cbor.NewEncoder(rw)
+	d = cbor.NewDecoder(rw)
+	m = cbor.Marshal
+	u = cbor.Unmarshal
+	return
+}
+
+func (a *raw) UnmarshalCBOR(b []byte) error {
+	a.bytes = append([]byte(nil), b...)
+	return nil
+}
+
+func (a *raw) MarshalCBOR() ([]byte, error) {
+	return a.bytes, nil
+}
@@ -0,0 +1,74 @@
+package jrpc
+
+import (
+	"context"
+	"errors"
+	"github.com/fxamacker/cbor/v2"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCborCodecs(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	router := NewRouter("test").Codecs(CborCodecs)
+	router.Func("sum", func(a, b int) int { return a + b })
+	router.Func("echo", func(arg testRouterStruct) testRouterStruct { return arg })
+	router.Func("fail", func() error { return errors.New("failed") })
+	router.Func("pair", func() (int, string) { return 1, "a" })
+
+	client := serve(ctx, t, router)
+	conn := NewFlow(client)
+	conn.Codecs(CborCodecs)
+
+	t.Run("array args", func(t *testing.T) {
+		// 10 is encoded as a new line byte
+		r, err := Query[int](conn, "sum", 10, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, 20, r)
+	})
+
+	t.Run("map args", func(t *testing.T) {
+		expected := testRouterStruct{I: 10, S: "a\nb"}
+		args, _ := cbor.Marshal(expected)
+		if _, err := client.Write(append([]byte("echo"), append(args, '\n')...)); err != nil {
+			t.Fatal(err)
+		}
+		r, err := Decode[testRouterStruct](conn)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, r)
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		_, err := Query[int](conn, "fail")
+		assert.EqualError(t, err, "failed")
+	})
+
+	t.Run("multiple results", func(t *testing.T) {
+		r, err := Query[[]any](conn, "pair")
+		assert.NoError(t, err)
+		assert.Equal(t, []any{uint64(1), "a"}, r)
+	})
+}
+
+func TestCborArgsDecoder_Decode(t *testing.T) {
+	args, _ := cbor.Marshal([]any{10, "\n", []byte{0xff}, map[string]any{"a": 1}})
+	scanner := NewByteScannerReader(nil)
+	scanner.Append(append(args, []byte("\nnext\n")...))
+
+	var a int
+	var s string
+	var b []byte
+	var m map[string]int
+	err := NewCborArgsDecoder().Decode(scanner, []any{&a, &s, &b, &m})
+	assert.NoError(t, err)
+	assert.Equal(t, 10, a)
+	assert.Equal(t, "\n", s)
+	assert.Equal(t, []byte{0xff}, b)
+	assert.Equal(t, map[string]int{"a": 1}, m)
+
+	command, err := readCommand(scanner)
+	assert.NoError(t, err)
+	assert.Equal(t, "next", command)
+}
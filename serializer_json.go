@@ -14,7 +14,7 @@ func JsonCodecs(rw io.ReadWriter) (e Encoder, d Decoder, m Marshal, u Unmarshal)
 }
 
 func (a *raw) UnmarshalJSON(b []byte) error {
-	a.bytes = b
+	a.bytes = append([]byte(nil), b...)
 	return nil
 }
 
@@ -24,4 +24,7 @@ func (a *raw) MarshalJSON() ([]byte, error) {
 
 type Failure struct {
 	Error string `json:"error"`
+	Code  int    `json:"code,omitempty"`
+	Type  string `json:"type,omitempty"`
+	Data  any    `json:"data,omitempty"`
 }
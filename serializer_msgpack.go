@@ -0,0 +1,44 @@
+package jrpc
+
+import (
+	"bytes"
+	"github.com/vmihailenco/msgpack/v5"
+	"io"
+)
+
+func MsgpackCodecs(rw io.ReadWriter) (e Encoder, d Decoder, m Marshal, u Unmarshal) {
+	enc := msgpack.NewEncoder(rw)
+	enc.SetCustomStructTag("json")
+	dec := msgpack.NewDecoder(rw)
+	dec.SetCustomStructTag("json")
+	e = enc
+	d = dec
+	m = msgpackMarshal
+	u = msgpackUnmarshal
+	return
+}
+
+// msgpackMarshal encodes the value using json struct tags, same as JsonCodecs.
+func msgpackMarshal(v any) ([]byte, error) {
+	b := bytes.Buffer{}
+	enc := msgpack.NewEncoder(&b)
+	enc.SetCustomStructTag("json")
+	err := enc.Encode(v)
+	return b.Bytes(), err
+}
+
+// msgpackUnmarshal decodes the value using json struct tags, same as JsonCodecs.
+func msgpackUnmarshal(data []byte, v any) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	return dec.Decode(v)
+}
+
+func (a *raw) UnmarshalMsgpack(b []byte) error {
+	a.bytes = append([]byte(nil), b...)
+	return nil
+}
+
+func (a *raw) MarshalMsgpack() ([]byte, error) {
+	return a.bytes, nil
+}
@@ -0,0 +1,57 @@
+package jrpc
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMsgpackCodecs(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	router := NewRouter("test")
+	router.Func("sum", func(a, b int) int { return a + b })
+	router.Func("echo", func(arg testRouterStruct) testRouterStruct { return arg })
+	router.Func("fail", func() error { return errors.New("failed") })
+	router.Func("pair", func() (int, string) { return 1, "a" })
+
+	conn := NewFlow(serve(ctx, t, router.With().Codecs(MsgpackCodecs).Decoders(NewMsgpackArgsDecoder())))
+	conn.Codecs(MsgpackCodecs)
+
+	t.Run("array args", func(t *testing.T) {
+		// 10 is encoded as a new line byte
+		r, err := Query[int](conn, "sum", 10, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, 20, r)
+	})
+
+	t.Run("map args", func(t *testing.T) {
+		expected := testRouterStruct{I: 10, S: "a\nb"}
+		if err := conn.Call("echo", expected); err != nil {
+			t.Fatal(err)
+		}
+		r, err := Decode[testRouterStruct](conn)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, r)
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		_, err := Query[int](conn, "fail")
+		assert.EqualError(t, err, "failed")
+	})
+
+	t.Run("multiple results", func(t *testing.T) {
+		r, err := Query[[]any](conn, "pair")
+		assert.NoError(t, err)
+		assert.EqualValues(t, []any{int8(1), "a"}, r)
+	})
+
+	t.Run("json connection", func(t *testing.T) {
+		conn := NewFlow(serve(ctx, t, router))
+		r, err := Query[int](conn, "sum", 1, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, r)
+	})
+}
@@ -0,0 +1,80 @@
+package jrpc
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+// Session is a bidirectional stream. Values sent to the service feed the
+// receive-only channel argument of the handler, and the values of its
+// channel result are received from the embedded Stream.
+type Session[T any, R any] struct {
+	*Stream[R]
+	mu     sync.Mutex
+	closed bool
+}
+
+// OpenSession calls the method with stream argument and channel result.
+func OpenSession[T any, R any](conn Conn, method string, args ...any) (*Session[T, R], error) {
+	return OpenSessionContext[T, R](context.Background(), conn, method, args...)
+}
+
+// OpenSessionContext is OpenSession which is closed when the context is done.
+func OpenSessionContext[T any, R any](ctx context.Context, conn Conn, method string, args ...any) (s *Session[T, R], err error) {
+	s = &Session[T, R]{}
+	// finish the input when the output ends, so the service is not left waiting for it
+	end := func(conn Conn) { _ = s.closeSend(conn) }
+	if s.Stream, err = openStream[R](ctx, conn, end, method, args...); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// Send sends the value to the service.
+func (s *Session[T, R]) Send(value T) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return io.ErrClosedPipe
+	}
+	return s.conn.Encode(value)
+}
+
+// CloseSend finishes the input with the end of stream frame, the output can be still received.
+func (s *Session[T, R]) CloseSend() error {
+	return s.closeSend(s.conn)
+}
+
+func (s *Session[T, R]) closeSend(conn Conn) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	return conn.Encode(EndOfStream)
+}
+
+// Exchange opens a session fed with the values from the channel, and returns
+// the channel of values received from the service.
+func Exchange[R any, T any](conn Conn, method string, values <-chan T, args ...any) (<-chan R, error) {
+	s, err := OpenSession[T, R](conn, method, args...)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		defer s.CloseSend()
+		for {
+			select {
+			case v, ok := <-values:
+				if !ok || s.Send(v) != nil {
+					return
+				}
+			case <-s.Done():
+				return
+			}
+		}
+	}()
+	return s.Values(), nil
+}
@@ -0,0 +1,73 @@
+package jrpc
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestSession(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	router := NewRouter("test")
+	router.Func("echo", func(n int, in <-chan string) <-chan string {
+		out := make(chan string)
+		go func() {
+			defer close(out)
+			for s := range in {
+				out <- strings.Repeat(s, n)
+			}
+		}()
+		return out
+	})
+	router.Func("deny", func(in <-chan string) (<-chan string, error) {
+		return nil, ErrUnauthorized
+	})
+	router.Func("sum", func(a, b int) int { return a + b })
+
+	t.Run("echo", func(t *testing.T) {
+		s, err := OpenSession[string, string](NewFlow(serve(ctx, t, router)), "echo", 2)
+		assert.NoError(t, err)
+		assert.NoError(t, s.Send("a"))
+		assert.Equal(t, "aa", <-s.Values())
+		assert.NoError(t, s.Send("b"))
+		assert.Equal(t, "bb", <-s.Values())
+		assert.NoError(t, s.CloseSend())
+		assert.ErrorIs(t, s.Send("c"), io.ErrClosedPipe)
+		for range s.Values() {
+		}
+		assert.NoError(t, s.Err())
+		assert.True(t, s.Ended())
+	})
+
+	t.Run("exchange", func(t *testing.T) {
+		in := make(chan string, 2)
+		in <- "a"
+		in <- "b"
+		close(in)
+		out, err := Exchange[string](NewFlow(serve(ctx, t, router)), "echo", in, 3)
+		assert.NoError(t, err)
+		var values []string
+		for v := range out {
+			values = append(values, v)
+		}
+		assert.Equal(t, []string{"aaa", "bbb"}, values)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		conn := NewFlow(serve(ctx, t, router))
+		s, err := OpenSession[string, string](conn, "deny")
+		assert.NoError(t, err)
+		for range s.Values() {
+		}
+		assert.ErrorIs(t, s.Err(), ErrUnauthorized)
+
+		// the input is finished, so the connection can be reused
+		r, err := Query[int](conn, "sum", 1, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, r)
+	})
+}
@@ -0,0 +1,71 @@
+package jrpc
+
+import (
+	"context"
+	"github.com/cryptopunkscc/astrald/auth/id"
+	"io"
+	"os"
+	"os/exec"
+)
+
+type pipeConn struct {
+	io.Reader
+	io.Writer
+	close func() error
+}
+
+func (c pipeConn) Close() error {
+	return c.close()
+}
+
+// Stdio returns a connection reading from stdin and writing to stdout.
+func Stdio() io.ReadWriteCloser {
+	return pipeConn{Reader: os.Stdin, Writer: os.Stdout, close: func() error { return nil }}
+}
+
+// Serve handles commands sent through conn until it is closed for reading.
+func (r *Router) Serve(ctx context.Context, conn io.ReadWriteCloser) (err error) {
+	r.registerApi()
+	rr := r.Query(r.port)
+	if !rr.authorized(ctx, id.Anyone, conn) {
+		return ErrUnauthorized
+	}
+	return rr.Handle(ctx, conn, id.Anyone, conn)
+}
+
+// ServeStdio attaches the router to the process stdin and stdout, so the
+// service can be executed as a child process. Logs must not be written to
+// stdout.
+func (r *Router) ServeStdio(ctx context.Context) error {
+	return r.Serve(ctx, Stdio())
+}
+
+// ExecFlow starts the command and connects to its stdin and stdout.
+func ExecFlow(name string, args ...string) (Conn, error) {
+	return CmdFlow(exec.Command(name, args...))
+}
+
+// CmdFlow starts the command and connects to its stdin and stdout.
+// Closing the connection closes stdin and waits for the command to exit.
+func CmdFlow(cmd *exec.Cmd) (c Conn, err error) {
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		_ = stdin.Close()
+		return
+	}
+	if err = cmd.Start(); err != nil {
+		return
+	}
+	return NewFlow(pipeConn{
+		Reader: stdout,
+		Writer: stdin,
+		close: func() error {
+			_ = stdin.Close()
+			return cmd.Wait()
+		},
+	}), nil
+}
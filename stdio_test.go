@@ -0,0 +1,75 @@
+package jrpc
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestStdioHelperProcess(t *testing.T) {
+	if os.Getenv("JRPC_STDIO_HELPER") != "1" {
+		return
+	}
+	router := NewRouter("test")
+	router.Func("sum", func(a, b int) int { return a + b })
+	router.Func("count", func(n int) <-chan int {
+		c := make(chan int)
+		go func() {
+			defer close(c)
+			for i := 0; i < n; i++ {
+				c <- i
+			}
+		}()
+		return c
+	})
+	if err := router.ServeStdio(context.Background()); err != nil {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func TestCmdFlow(t *testing.T) {
+	cmd := exec.Command(os.Args[0], "-test.run=^TestStdioHelperProcess$")
+	cmd.Env = append(os.Environ(), "JRPC_STDIO_HELPER=1")
+	conn, err := CmdFlow(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := Query[int](conn, "sum", 1, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, r)
+
+	api, err := Query[[]string](conn, "api")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"sum", "count"}, api)
+
+	if err = Call(conn, "count", 3); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		r, err = Decode[int](conn)
+		assert.NoError(t, err)
+		assert.Equal(t, i, r)
+	}
+
+	assert.NoError(t, conn.Close())
+}
+
+func TestCmdFlow_stdoutTaken(t *testing.T) {
+	cmd := exec.Command(os.Args[0])
+	cmd.Stdout = io.Discard
+	_, err := CmdFlow(cmd)
+	assert.Error(t, err)
+
+	// the stdin pipe is closed, so its reading end gets EOF
+	stdin := cmd.Stdin.(*os.File)
+	defer stdin.Close()
+	_ = stdin.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = stdin.Read(make([]byte, 1))
+	assert.ErrorIs(t, err, io.EOF)
+}
@@ -0,0 +1,187 @@
+package jrpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+)
+
+// Stream is a subscription to the values streamed by the service. The
+// values channel is closed when the stream ends and Err tells why.
+type Stream[R any] struct {
+	conn    Conn
+	values  chan R
+	done    chan struct{}
+	closing chan struct{}
+	once    sync.Once
+	mu      sync.Mutex
+	aborted bool
+	reason  error
+	stop    func() bool
+	end     func(Conn)
+	err     error
+	ended   bool
+}
+
+// RemoteError is a failure sent by the service, which ended the stream.
+type RemoteError struct {
+	Err error
+}
+
+func (e *RemoteError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RemoteError) Unwrap() error {
+	return e.Err
+}
+
+// SubscribeStream calls the method and streams the decoded results.
+func SubscribeStream[R any](conn Conn, method string, args ...any) (s *Stream[R], err error) {
+	return SubscribeStreamContext[R](context.Background(), conn, method, args...)
+}
+
+// SubscribeStreamContext is SubscribeStream which is closed when the
+// context is done, the context error is reported by Err.
+func SubscribeStreamContext[R any](ctx context.Context, conn Conn, method string, args ...any) (s *Stream[R], err error) {
+	return openStream[R](ctx, conn, nil, method, args...)
+}
+
+// openStream calls the method and starts reading the stream. The end
+// function is called with the connection when the stream ends.
+func openStream[R any](ctx context.Context, conn Conn, end func(Conn), method string, args ...any) (s *Stream[R], err error) {
+	s = &Stream[R]{
+		conn:    conn.Copy(),
+		values:  make(chan R),
+		done:    make(chan struct{}),
+		closing: make(chan struct{}),
+		end:     end,
+	}
+	s.stop = context.AfterFunc(ctx, func() { _ = s.abort(ctx.Err()) })
+	if err = CallContext(ctx, s.conn, method, args...); err != nil {
+		s.stop()
+		s.conn.Flush()
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return nil, err
+	}
+	go s.read()
+	return
+}
+
+// Values returns the channel of streamed values.
+func (s *Stream[R]) Values() <-chan R {
+	return s.values
+}
+
+// Done returns a channel which is closed when the stream ends.
+func (s *Stream[R]) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err returns nil while the stream is open or if it ended cleanly. A failure
+// sent by the service is returned as *RemoteError, other errors come from
+// the connection.
+func (s *Stream[R]) Err() error {
+	select {
+	case <-s.done:
+		return s.err
+	default:
+		return nil
+	}
+}
+
+// Ended reports whether the service finished the stream with the end of
+// stream frame. Streams of plain channels end with the connection instead.
+func (s *Stream[R]) Ended() bool {
+	select {
+	case <-s.done:
+		return s.ended
+	default:
+		return false
+	}
+}
+
+// Close unsubscribes from the stream by closing the connection.
+func (s *Stream[R]) Close() error {
+	return s.abort(nil)
+}
+
+func (s *Stream[R]) abort(reason error) (err error) {
+	s.once.Do(func() {
+		s.mu.Lock()
+		s.aborted, s.reason = true, reason
+		s.mu.Unlock()
+		close(s.closing)
+		err = s.conn.Close()
+	})
+	return
+}
+
+func (s *Stream[R]) read() {
+	defer close(s.done)
+	defer close(s.values)
+	defer s.conn.Flush()
+	defer s.stop()
+	if s.end != nil {
+		defer s.end(s.conn)
+	}
+	for {
+		var r R
+		if remote, err := decodeRemote(s.conn, &r); err != nil {
+			s.finish(err, remote)
+			return
+		}
+		select {
+		case s.values <- r:
+		case <-s.closing:
+			s.finish(nil, false)
+			return
+		}
+	}
+}
+
+func (s *Stream[R]) finish(err error, remote bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case s.aborted:
+		s.err = s.reason
+	case errors.Is(err, ErrEndOfStream):
+		s.ended = true
+	case errors.Is(err, io.EOF):
+	case remote:
+		s.err = &RemoteError{Err: err}
+	default:
+		s.err = err
+	}
+}
+
+// remoteValue wraps the decoded value and marks failures sent by the service.
+type remoteValue struct {
+	value  any
+	remote bool
+}
+
+// decodeRemote decodes the value and reports if the error is a failure sent
+// by the service. Only the conns of the package know remoteValue, the errors
+// of other conns are never reported as remote.
+func decodeRemote(conn Conn, value any) (remote bool, err error) {
+	switch conn.(type) {
+	case *Flow, *Request, *MuxFlow, *muxCall, *pooledRequest, *JsonRpcFlow:
+		v := remoteValue{value: value}
+		err = conn.Decode(&v)
+		return v.remote, err
+	}
+	return false, conn.Decode(value)
+}
+
+// unwrapRemote returns the value to decode into and the mark of remote failure.
+func unwrapRemote(value any) (any, *bool) {
+	if v, ok := value.(*remoteValue); ok {
+		return v.value, &v.remote
+	}
+	return value, new(bool)
+}
@@ -0,0 +1,173 @@
+package jrpc
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net"
+	"testing"
+)
+
+func TestStream(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	stopped := make(chan struct{}, 1)
+	router := NewRouter("test")
+	router.Func("count", func(n int) <-chan any {
+		c := make(chan any, n+1)
+		for i := 0; i < n; i++ {
+			c <- i
+		}
+		close(c)
+		return c
+	})
+	router.Func("fail", func() <-chan any {
+		c := make(chan any, 2)
+		c <- 1
+		c <- ErrUnauthorized
+		close(c)
+		return c
+	})
+	router.Func("emit", func(n int, fail bool) *Emitter[int] {
+		e := NewEmitter[int](n)
+		for i := 0; i < n; i++ {
+			e.C() <- i
+		}
+		if fail {
+			e.Close(ErrUnauthorized)
+		} else {
+			e.Close(nil)
+		}
+		return e
+	})
+	router.Func("ticks", func(ctx context.Context) <-chan int {
+		c := make(chan int)
+		go func() {
+			defer close(c)
+			defer func() { stopped <- struct{}{} }()
+			for i := 0; ; i++ {
+				select {
+				case c <- i:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+		return c
+	})
+
+	collect := func(s *Stream[int]) (values []int) {
+		for v := range s.Values() {
+			values = append(values, v)
+		}
+		return
+	}
+
+	t.Run("clean end", func(t *testing.T) {
+		s, err := SubscribeStream[int](NewFlow(serve(ctx, t, router)), "count", 3)
+		assert.NoError(t, err)
+		assert.Equal(t, []int{0, 1, 2}, collect(s))
+		<-s.Done()
+		assert.NoError(t, s.Err())
+	})
+
+	t.Run("remote error", func(t *testing.T) {
+		s, err := SubscribeStream[int](NewFlow(serve(ctx, t, router)), "fail")
+		assert.NoError(t, err)
+		assert.Equal(t, []int{1}, collect(s))
+		var remote *RemoteError
+		assert.ErrorAs(t, s.Err(), &remote)
+		assert.ErrorIs(t, s.Err(), ErrUnauthorized)
+	})
+
+	t.Run("end frame", func(t *testing.T) {
+		for _, conn := range []Conn{NewFlow(serve(ctx, t, router)), NewMuxFlow(serve(ctx, t, router))} {
+			s, err := SubscribeStream[int](conn, "emit", 2, false)
+			assert.NoError(t, err)
+			assert.Equal(t, []int{0, 1}, collect(s))
+			assert.NoError(t, s.Err())
+			assert.True(t, s.Ended())
+		}
+	})
+
+	t.Run("error frame", func(t *testing.T) {
+		for _, conn := range []Conn{NewFlow(serve(ctx, t, router)), NewMuxFlow(serve(ctx, t, router))} {
+			s, err := SubscribeStream[int](conn, "emit", 2, true)
+			assert.NoError(t, err)
+			assert.Equal(t, []int{0, 1}, collect(s))
+			assert.ErrorIs(t, s.Err(), ErrUnauthorized)
+			assert.False(t, s.Ended())
+		}
+	})
+
+	t.Run("plain channel end", func(t *testing.T) {
+		s, err := SubscribeStream[int](NewFlow(serve(ctx, t, router)), "count", 1)
+		assert.NoError(t, err)
+		collect(s)
+		assert.NoError(t, s.Err())
+		assert.False(t, s.Ended())
+	})
+
+	t.Run("conn outside the package", func(t *testing.T) {
+		s, err := SubscribeStream[int](foreignConn{NewFlow(serve(ctx, t, router))}, "fail")
+		assert.NoError(t, err)
+		assert.Equal(t, []int{1}, collect(s))
+		assert.ErrorIs(t, s.Err(), ErrUnauthorized)
+		var remote *RemoteError
+		assert.False(t, errors.As(s.Err(), &remote))
+	})
+
+	t.Run("transport error", func(t *testing.T) {
+		server, client := net.Pipe()
+		go func() {
+			_, _ = bufio.NewReader(server).ReadString('\n')
+			_, _ = server.Write([]byte("1\n{"))
+			_ = server.Close()
+		}()
+		s, err := SubscribeStream[int](NewFlow(client), "")
+		assert.NoError(t, err)
+		assert.Equal(t, []int{1}, collect(s))
+		var remote *RemoteError
+		assert.Error(t, s.Err())
+		assert.False(t, errors.As(s.Err(), &remote))
+		assert.False(t, errors.Is(s.Err(), io.EOF))
+	})
+
+	t.Run("close", func(t *testing.T) {
+		s, err := SubscribeStream[int](NewMuxFlow(serve(ctx, t, router)), "ticks")
+		assert.NoError(t, err)
+		assert.Equal(t, 0, <-s.Values())
+		assert.NoError(t, s.Close())
+		collect(s)
+		assert.NoError(t, s.Err())
+		<-stopped
+	})
+
+	t.Run("context", func(t *testing.T) {
+		conn := NewMuxFlow(serve(ctx, t, router))
+		ctx, cancel := context.WithCancel(ctx)
+		s, err := SubscribeStreamContext[int](ctx, conn, "ticks")
+		assert.NoError(t, err)
+		assert.Equal(t, 0, <-s.Values())
+		cancel()
+		collect(s)
+		assert.ErrorIs(t, s.Err(), context.Canceled)
+		<-stopped
+	})
+}
+
+// foreignConn is a Conn implemented outside the package, which decodes
+// only the values of the caller.
+type foreignConn struct{ Conn }
+
+func (c foreignConn) Copy() Conn { return foreignConn{c.Conn.Copy()} }
+
+func (c foreignConn) Decode(value any) error {
+	if _, ok := value.(*remoteValue); ok {
+		return errors.New("unknown value type")
+	}
+	return c.Conn.Decode(value)
+}
@@ -0,0 +1,117 @@
+package jrpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// A command can be prefixed with a request ID, e.g. "#1:sum[1,2]". Responses
+// to such commands are tagged with the ID, so concurrent calls can share a
+// connection. The end of a stream is marked with a done message. A request
+// ID followed by an empty command, e.g. "#1:", cancels the call.
+
+// tagged is a response to a command with request ID.
+type tagged struct {
+	ID    uint64 `json:"id"`
+	Value any    `json:"value,omitempty"`
+	Done  bool   `json:"done,omitempty"`
+}
+
+// taggedMessage is a tagged response with undecoded value.
+type taggedMessage struct {
+	ID    uint64 `json:"id"`
+	Value raw    `json:"value"`
+	Done  bool   `json:"done,omitempty"`
+}
+
+// cutRequestId cuts the request ID prefix from the command, the ID is 0 if there is none.
+func cutRequestId(command string) (requestId uint64, rest string) {
+	rest = command
+	if !strings.HasPrefix(command, "#") {
+		return
+	}
+	id, rest, ok := strings.Cut(command[1:], ":")
+	if !ok {
+		return 0, command
+	}
+	if requestId, _ = strconv.ParseUint(id, 10, 64); requestId == 0 {
+		return 0, command
+	}
+	return
+}
+
+// responder serializes writes of concurrent responses and tracks running calls.
+type responder struct {
+	sync.WaitGroup
+	mu      sync.Mutex
+	wmu     sync.Mutex // writes can block on slow clients, cancels must not wait for them
+	rpc     *Flow
+	cancels map[uint64]context.CancelFunc
+}
+
+// context returns the context of a tagged call, which is cancelled on
+// release or when the client cancels the call.
+func (w *responder) context(ctx context.Context, requestId uint64) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.cancels == nil {
+		w.cancels = map[uint64]context.CancelFunc{}
+	}
+	w.cancels[requestId] = cancel
+	return ctx, func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		cancel()
+		delete(w.cancels, requestId)
+	}
+}
+
+func (w *responder) cancel(requestId uint64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if cancel, ok := w.cancels[requestId]; ok {
+		cancel()
+	}
+}
+
+func (w *responder) encoder(requestId uint64) func(any) error {
+	return func(value any) error {
+		w.wmu.Lock()
+		defer w.wmu.Unlock()
+		if requestId == 0 {
+			return encodeResponse(w.rpc, value)
+		}
+		if err, ok := value.(error); ok {
+			value = newFailure(err)
+		}
+		return w.rpc.Encode(tagged{ID: requestId, Value: value})
+	}
+}
+
+func (w *responder) done(requestId uint64) error {
+	w.wmu.Lock()
+	defer w.wmu.Unlock()
+	return w.rpc.Encode(tagged{ID: requestId, Done: true})
+}
+
+// respondTagged sends the response tagged with the request ID. Streams and
+// calls finished with io.EOF are followed by done message.
+func (r *Router) respondTagged(ctx context.Context, w *responder, requestId uint64, err error, result ...any) {
+	if err == nil && isReader(result) {
+		if c, ok := result[0].(io.Closer); ok {
+			_ = c.Close()
+		}
+		err, result = ErrTaggedReader, nil
+	}
+	if r.respondWith(ctx, w.encoder(requestId), err, result...) {
+		return
+	}
+	if errors.Is(err, io.EOF) || err == nil && isStream(result) {
+		_ = w.done(requestId)
+	}
+}
@@ -0,0 +1,82 @@
+package jrpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A command can be prefixed with the time left to the deadline of the client,
+// e.g. "~1500ms:sum[1,2]", or "#1:~1500ms:sum[1,2]" with request ID. The
+// prefix is cut in Router.Query, so it works also for the method of queries,
+// JSON-RPC requests and HTTP paths, e.g. "test.~1500ms:sum?[1,2]". The
+// context of the call is cancelled when it is exceeded, and the deadline error
+// is sent instead of the response.
+
+func init() {
+	// clients decode the deadline error as context.DeadlineExceeded
+	RegisterError("deadline_exceeded", context.DeadlineExceeded)
+}
+
+// cutTimeout cuts the timeout prefix from the command, the timeout is 0 if there is none.
+func cutTimeout(command string) (timeout time.Duration, rest string) {
+	rest = command
+	if !strings.HasPrefix(command, "~") {
+		return
+	}
+	t, rest, ok := strings.Cut(command[1:], ":")
+	if !ok {
+		return 0, command
+	}
+	var err error
+	if timeout, err = time.ParseDuration(t); err != nil || timeout <= 0 {
+		return 0, command
+	}
+	return
+}
+
+// timeoutPrefix returns the prefix of the command sending the time left to
+// the deadline of the context, or empty string if there is no deadline.
+func timeoutPrefix(ctx context.Context) string {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return ""
+	}
+	ms := time.Until(deadline).Milliseconds()
+	if ms < 1 {
+		ms = 1
+	}
+	return "~" + strconv.FormatInt(ms, 10) + "ms:"
+}
+
+// withTimeout returns the context of the call limited by the timeout of the
+// method and the timeout sent by the client, whichever is shorter.
+func (r *Router) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	timeout := r.timeout
+	if !r.registry.IsEmpty() {
+		if t := r.registry.Get().timeout; t > 0 && (timeout == 0 || t < timeout) {
+			timeout = t
+		}
+	}
+	if timeout == 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// deadline replaces the result with the deadline error if the call exceeded
+// its deadline. The readers of discarded result are closed.
+func deadline(ctx context.Context, result []any, err error) ([]any, error) {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		for _, r := range result {
+			if c, ok := r.(io.ReadCloser); ok {
+				_ = c.Close()
+			}
+		}
+		return nil, ctx.Err()
+	}
+	return result, err
+}
@@ -0,0 +1,138 @@
+package jrpc
+
+import (
+	"context"
+	"github.com/cryptopunkscc/astrald/auth/id"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRouter_Timeout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	cancelled := make(chan error, 1)
+	wait := func(ctx context.Context, n int) (int, error) {
+		<-ctx.Done()
+		cancelled <- ctx.Err()
+		return n, ctx.Err()
+	}
+	r := NewRouter("test")
+	r.Caller(NewCaller("slow").Func(wait).Timeout(20 * time.Millisecond))
+	r.Caller(NewCaller("sleep").Func(func() int {
+		time.Sleep(50 * time.Millisecond)
+		return 1
+	}).Timeout(10 * time.Millisecond))
+	r.Caller(NewCaller("ticks").Func(func(ctx context.Context) <-chan int {
+		c := make(chan int)
+		go func() {
+			defer close(c)
+			for i := 0; ctx.Err() == nil; i++ {
+				c <- i
+				time.Sleep(5 * time.Millisecond)
+			}
+		}()
+		return c
+	}).Timeout(20 * time.Millisecond))
+	r.Func("wait", wait)
+	deadlines := make(chan bool, 1)
+	r.Func("await", func(ctx context.Context) error {
+		_, ok := ctx.Deadline()
+		<-ctx.Done()
+		deadlines <- ok
+		return ctx.Err()
+	})
+
+	t.Run("method timeout", func(t *testing.T) {
+		_, err := Query[int](NewFlow(serve(ctx, t, r)), "slow", 1)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.ErrorIs(t, <-cancelled, context.DeadlineExceeded)
+	})
+
+	t.Run("late result", func(t *testing.T) {
+		_, err := Query[int](NewFlow(serve(ctx, t, r)), "sleep")
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		s, err := SubscribeStream[int](NewFlow(serve(ctx, t, r)), "ticks")
+		assert.NoError(t, err)
+		for range s.Values() {
+		}
+		var remote *RemoteError
+		assert.ErrorAs(t, s.Err(), &remote)
+		assert.ErrorIs(t, s.Err(), context.DeadlineExceeded)
+	})
+
+	t.Run("client deadline", func(t *testing.T) {
+		conn := NewFlow(serve(ctx, t, r))
+		ctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancel()
+		_, err := QueryContext[int](ctx, conn, "wait", 1)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.ErrorIs(t, <-cancelled, context.DeadlineExceeded)
+	})
+
+	t.Run("tagged", func(t *testing.T) {
+		_, err := Query[int](NewMuxFlow(serve(ctx, t, r)), "~20ms:wait", 1)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.ErrorIs(t, <-cancelled, context.DeadlineExceeded)
+	})
+
+	t.Run("request", func(t *testing.T) {
+		conn := NewRequest(id.Anyone, "test").(*Request)
+		conn.dialer = testDialer(func(identity id.Identity, query string) (io.ReadWriteCloser, error) {
+			server, client := net.Pipe()
+			go func() {
+				defer server.Close()
+				_ = r.routeQuery(ctx, testQuery{query: query, conn: server})
+			}()
+			return client, nil
+		})
+		ctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancel()
+		err := CommandContext(ctx, conn, "await")
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.True(t, <-deadlines)
+	})
+
+	t.Run("json-rpc", func(t *testing.T) {
+		conn := NewJsonRpcFlow(serve(ctx, t, r.With().JsonRpc()))
+		ctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancel()
+		err := CommandContext(ctx, conn, "await")
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.True(t, <-deadlines)
+	})
+
+	t.Run("http", func(t *testing.T) {
+		server := httptest.NewServer(NewHttpHandler(r))
+		defer server.Close()
+		res, err := http.Post(server.URL+"/test/~20ms:await", "application/json", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusInternalServerError, res.StatusCode)
+		assert.Equal(t, `{"error":"context deadline exceeded","type":"deadline_exceeded"}`+"\n", string(body))
+		assert.True(t, <-deadlines)
+	})
+}
+
+// testQuery is an incoming query served over an in-memory connection.
+type testQuery struct {
+	query string
+	conn  net.Conn
+}
+
+func (q testQuery) Query() string                       { return q.query }
+func (q testQuery) RemoteIdentity() id.Identity         { return id.Anyone }
+func (q testQuery) Accept() (io.ReadWriteCloser, error) { return q.conn, nil }
+func (q testQuery) Reject() error                       { return q.conn.Close() }
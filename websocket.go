@@ -0,0 +1,205 @@
+package jrpc
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+)
+
+// wsConn is a minimal RFC 6455 connection. Every write is sent as a single
+// text frame, or binary frame once a binary codec is negotiated, reads
+// return the payload of received data frames.
+type wsConn struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	client  bool
+	binary  bool
+	payload []byte
+	wmu     sync.Mutex
+	closed  bool
+}
+
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xa
+)
+
+const wsMaxPayload = 16 << 20
+
+const wsGuid = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+const wsProtocolError = 1002
+
+var ErrWsPayloadTooLarge = errors.New("websocket payload too large")
+var ErrWsMasking = errors.New("invalid websocket frame masking")
+
+func newWsConn(conn net.Conn, reader *bufio.Reader, client bool) *wsConn {
+	if reader == nil {
+		reader = bufio.NewReader(conn)
+	}
+	return &wsConn{conn: conn, reader: reader, client: client}
+}
+
+func wsAccept(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + wsGuid))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func (c *wsConn) Read(p []byte) (n int, err error) {
+	for len(c.payload) == 0 {
+		if err = c.next(); err != nil {
+			return
+		}
+	}
+	n = copy(p, c.payload)
+	c.payload = c.payload[n:]
+	return
+}
+
+func (c *wsConn) next() (err error) {
+	var h [2]byte
+	if _, err = io.ReadFull(c.reader, h[:]); err != nil {
+		return
+	}
+	fin := h[0]&0x80 != 0
+	op := h[0] & 0x0f
+	masked := h[1]&0x80 != 0
+	if masked == c.client {
+		// clients must mask their frames and servers must not
+		_ = c.write(wsClose, binary.BigEndian.AppendUint16(nil, wsProtocolError))
+		return ErrWsMasking
+	}
+	size := uint64(h[1] & 0x7f)
+	switch size {
+	case 126:
+		var b [2]byte
+		if _, err = io.ReadFull(c.reader, b[:]); err != nil {
+			return
+		}
+		size = uint64(binary.BigEndian.Uint16(b[:]))
+	case 127:
+		var b [8]byte
+		if _, err = io.ReadFull(c.reader, b[:]); err != nil {
+			return
+		}
+		size = binary.BigEndian.Uint64(b[:])
+	}
+	if size > wsMaxPayload {
+		return ErrWsPayloadTooLarge
+	}
+	var mask [4]byte
+	if masked {
+		if _, err = io.ReadFull(c.reader, mask[:]); err != nil {
+			return
+		}
+	}
+	payload := make([]byte, size)
+	if _, err = io.ReadFull(c.reader, payload); err != nil {
+		return
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+
+	switch op {
+	case wsClose:
+		_ = c.write(wsClose, payload)
+		return io.EOF
+	case wsPing:
+		return c.write(wsPong, payload)
+	case wsPong:
+		return
+	case wsContinuation, wsText, wsBinary:
+		// each message sent by a server side client is a single command line
+		if fin && !c.client && (len(payload) == 0 || payload[len(payload)-1] != '\n') {
+			payload = append(payload, '\n')
+		}
+		c.payload = payload
+	}
+	return
+}
+
+func (c *wsConn) Write(p []byte) (n int, err error) {
+	c.wmu.Lock()
+	op := byte(wsText)
+	if c.binary {
+		op = wsBinary
+	}
+	c.wmu.Unlock()
+	if err = c.write(op, p); err != nil {
+		return
+	}
+	return len(p), nil
+}
+
+func (c *wsConn) write(op byte, payload []byte) (err error) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if c.closed {
+		return net.ErrClosed
+	}
+	frame := make([]byte, 0, len(payload)+14)
+	frame = append(frame, 0x80|op)
+	var mask byte
+	if c.client {
+		mask = 0x80
+	}
+	switch l := len(payload); {
+	case l < 126:
+		frame = append(frame, mask|byte(l))
+	case l <= 0xffff:
+		frame = append(frame, mask|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(l))
+	default:
+		frame = append(frame, mask|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(l))
+	}
+	if c.client {
+		var key [4]byte
+		if _, err = rand.Read(key[:]); err != nil {
+			return
+		}
+		frame = append(frame, key[:]...)
+		for i, b := range payload {
+			frame = append(frame, b^key[i%4])
+		}
+	} else {
+		frame = append(frame, payload...)
+	}
+	_, err = c.conn.Write(frame)
+	if op == wsClose {
+		c.closed = true
+	}
+	return
+}
+
+// wsBinaryFrames switches the websocket connection under the flow to binary
+// frames if the codec is binary, or back to text frames otherwise.
+func wsBinaryFrames(conn any, codec Codec) {
+	if f, ok := conn.(*Flow); ok {
+		conn = f.WriteCloser
+	}
+	if c, ok := conn.(*wsConn); ok {
+		c.wmu.Lock()
+		c.binary = codec.Binary
+		c.wmu.Unlock()
+	}
+}
+
+func (c *wsConn) Close() error {
+	_ = c.write(wsClose, binary.BigEndian.AppendUint16(nil, 1000))
+	return c.conn.Close()
+}